@@ -33,6 +33,7 @@ func RegisterRoutes(router *gin.Engine, log *logger.Logger, db *pgxpool.Pool) {
 	RegisterPingRoutes(apiRouter)
 	repo.RegisterRepositoryRoutes(apiRouter, db)
 	repo.RegisterRefsRoutes(apiRouter, db)
+	repo.RegisterTagsRoutes(apiRouter, db)
 	repo.RegisterObjectsRoutes(apiRouter, db)
 	repo.RegisterSnapshotsRoutes(apiRouter, db)
 	users.RegisterUserRoutes(apiRouter, db)
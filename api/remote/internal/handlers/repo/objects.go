@@ -38,7 +38,7 @@ func getObjects(db *pgxpool.Pool) gin.HandlerFunc {
 		defer coredbConnection.Close()
 
 		var objects []Object
-		rawObjects, err := coredb.ListObjects(coredbConnection)
+		rawObjects, err := coredb.ListObjectInfo(coredbConnection)
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/greedypanda0/kuro/api/remote/database"
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func RegisterTagsRoutes(router gin.IRoutes, db *pgxpool.Pool) {
+	router.GET("repositories/:id/tags", getTags(db))
+	router.GET("repositories/:id/tags/:tag", getTag(db))
+}
+
+func getTags(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repoID := c.Param("id")
+		repo, err := database.GetRepo(db, c, repoID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if repo == nil {
+			c.JSON(404, gin.H{"error": "repository not found"})
+			return
+		}
+
+		path := filepath.Join("data", repo.UserID, repo.Name+".db")
+
+		coredbConnection, err := coredb.OpenDB(path)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		defer coredbConnection.Close()
+
+		tags, err := coredb.ListTags(coredbConnection)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"tags": tags})
+	}
+}
+
+func getTag(db *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repoID := c.Param("id")
+		tagName := c.Param("tag")
+		repo, err := database.GetRepo(db, c, repoID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if repo == nil {
+			c.JSON(404, gin.H{"error": "repository not found"})
+			return
+		}
+
+		path := filepath.Join("data", repo.UserID, repo.Name+".db")
+
+		coredbConnection, err := coredb.OpenDB(path)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		defer coredbConnection.Close()
+
+		tag, err := coredb.GetTag(coredbConnection, tagName)
+		if err == coreerrors.ErrTagNotFound {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{"tag": tag})
+	}
+}
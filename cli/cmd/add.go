@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/greedypanda0/kuro/cli/internal/config"
@@ -18,13 +22,27 @@ import (
 )
 
 var addCommand = &cobra.Command{
-	Use:          "add <path>",
-	Short:        "Add files to the stage",
-	Long:         "Add files or directories to the staging area",
-	Args:         cobra.ExactArgs(1),
+	Use:   "add [path]",
+	Short: "Add files to the stage",
+	Long: `Store the current content of files or directories in the staging area;
+later edits are not staged until added again. Tracked files missing from
+the workspace are staged as deletions. --all stages every addition,
+modification and deletion in the workspace. --patch walks the hunks between
+the staged content of each modified file, or HEAD when it is not staged,
+and the workspace, and stages only the accepted ones.`,
+	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		arg := args[0]
+		all, _ := cmd.Flags().GetBool("all")
+		if len(args) == 0 && !all {
+			ui.Println(ui.Error("Nothing specified, pass a path or --all"))
+			return errors.New("no path given")
+		}
+
+		arg := "."
+		if len(args) == 1 {
+			arg = args[0]
+		}
 
 		root, err := config.RepoRoot()
 		if err != nil {
@@ -68,8 +86,26 @@ var addCommand = &cobra.Command{
 			return fmt.Errorf("path outside repository")
 		}
 
-		info, err := os.Stat(absPath)
+		headFiles, staged, err := ops.HeadAndStage(db)
 		if err != nil {
+			ui.Println(ui.Error("Failed to read stage"))
+			return err
+		}
+
+		// Tracked files under the path that are gone from the workspace
+		// leave the next snapshot.
+		var deletions []string
+		for _, path := range trackedPaths(headFiles, staged) {
+			if relToRoot != "." && path != relToRoot && !strings.HasPrefix(path, relToRoot+"/") {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); os.IsNotExist(err) {
+				deletions = append(deletions, path)
+			}
+		}
+
+		info, err := os.Stat(absPath)
+		if err != nil && (!os.IsNotExist(err) || len(deletions) == 0) {
 			if os.IsNotExist(err) {
 				ui.Println(ui.Error(fmt.Sprintf("Path does not exist: %s", arg)))
 				return err
@@ -78,9 +114,15 @@ var addCommand = &cobra.Command{
 			return err
 		}
 
+		if patch, _ := cmd.Flags().GetBool("patch"); patch {
+			return addPatch(cmd, db, root, relToRoot)
+		}
+
 		filesToStage := []string{}
 
-		if info.IsDir() {
+		switch {
+		case info == nil:
+		case info.IsDir():
 			ui.Println(ui.Step("Scanning directory..."))
 
 			files, err := ops.ReadDir(absPath)
@@ -96,13 +138,13 @@ var addCommand = &cobra.Command{
 				}
 				filesToStage = append(filesToStage, relPath)
 			}
-		} else {
+		default:
 			if !ops.IsIgnored(relToRoot, kuroIgnore) {
 				filesToStage = append(filesToStage, relToRoot)
 			}
 		}
 
-		total := len(filesToStage)
+		total := len(filesToStage) + len(deletions)
 		if total == 0 {
 			ui.Println(ui.Step("Nothing to stage"))
 			return nil
@@ -112,26 +154,156 @@ var addCommand = &cobra.Command{
 		defer fmt.Print("\n")
 
 		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			previous := make(map[string]string, len(headFiles))
+			for _, f := range headFiles {
+				previous[f.Path] = f.ObjectHash
+			}
+
+			cache, err := ops.LoadStatCache(tx, root)
+			if err != nil {
+				return err
+			}
+
 			for i, file := range filesToStage {
 				ratio := float64(i+1) / float64(total)
 				fmt.Printf("\r%s", ui.Progress(30, ratio))
 
-				if err := coredb.AddStageFile(tx, file); err != nil {
+				objectHash, err := cache.Store(tx, file, previous[file])
+				if err != nil {
+					return err
+				}
+				if err := coredb.AddStageFile(tx, file, objectHash); err != nil {
 					return err
 				}
 			}
-			return nil
+			for i, path := range deletions {
+				ratio := float64(len(filesToStage)+i+1) / float64(total)
+				fmt.Printf("\r%s", ui.Progress(30, ratio))
+
+				if err := coredb.StageDeletion(tx, path); err != nil {
+					return err
+				}
+			}
+			return cache.Save(tx)
 		})
 		if err != nil {
 			ui.Println(ui.Error("Failed to stage files"))
 			return err
 		}
 
-		ui.Println(ui.Success(fmt.Sprintf("Staged %d file(s)", total)))
+		status := fmt.Sprintf("Staged %d file(s)", len(filesToStage))
+		if len(deletions) > 0 {
+			status += fmt.Sprintf(" and %d deletion(s)", len(deletions))
+		}
+		ui.Println(ui.Success(status))
 		return nil
 	},
 }
 
+// trackedPaths lists the paths the next commit would keep: HEAD and the
+// stage, minus staged deletions.
+func trackedPaths(headFiles []coredb.SnapshotFile, staged map[string]coredb.Stage) []string {
+	paths := make([]string, 0, len(headFiles)+len(staged))
+	for _, f := range headFiles {
+		if _, ok := staged[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+	}
+	for path, f := range staged {
+		if !f.Deleted {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// addPatch walks the hunks between the staged (or HEAD) content of the
+// tracked files under path and the workspace, and stages a version of each
+// file holding only the accepted hunks.
+func addPatch(cmd *cobra.Command, db *sql.DB, root, path string) error {
+	cache, err := ops.LoadStatCache(db, root)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read stat cache"))
+		return err
+	}
+
+	d, err := ops.DiffUnstaged(db, cache)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read workspace"))
+		return err
+	}
+
+	type patched struct {
+		path, base string
+		content    []byte
+	}
+	var (
+		pending []patched
+		in      = bufio.NewReader(cmd.InOrStdin())
+	)
+
+	for _, c := range ops.FilterChanges(d.Changes, []string{path}) {
+		if c.Status != ops.StatusModified {
+			continue
+		}
+
+		oldContent, newContent, err := ops.LoadChange(c, d.Old, d.New)
+		if errors.Is(err, coreerrors.ErrBinaryFile) {
+			ui.Println(ui.Warn("Skipping binary file " + c.Path))
+			continue
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to read " + c.Path))
+			return err
+		}
+
+		selected, quit, err := ops.SelectHunks(in, cmd.OutOrStdout(), c.Path, ops.SplitHunks(oldContent, newContent))
+		if err != nil {
+			ui.Println(ui.Error("Failed to read answer"))
+			return err
+		}
+		if len(selected) > 0 {
+			content, err := ops.ApplyHunks(oldContent, selected)
+			if err != nil {
+				ui.Println(ui.Error("Failed to apply hunks to " + c.Path))
+				return err
+			}
+			pending = append(pending, patched{path: c.Path, base: c.OldHash, content: content})
+		}
+		if quit {
+			break
+		}
+	}
+
+	if len(pending) == 0 {
+		ui.Println(ui.Step("Nothing staged"))
+		return nil
+	}
+
+	err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+		for _, p := range pending {
+			objectHash, err := ops.StoreObject(tx, p.content, p.base)
+			if err != nil {
+				return err
+			}
+			if err := coredb.AddStageFile(tx, p.path, objectHash); err != nil {
+				return err
+			}
+		}
+		return cache.Save(tx)
+	})
+	if err != nil {
+		ui.Println(ui.Error("Failed to stage hunks"))
+		return err
+	}
+
+	ui.Println(ui.Success(fmt.Sprintf("Staged hunks of %d file(s)", len(pending))))
+	return nil
+}
+
 func init() {
+	addCommand.Flags().BoolP("patch", "p", false, "choose the hunks to stage interactively")
+	addCommand.Flags().BoolP("all", "A", false, "stage all additions, modifications and deletions")
 	rootCommand.AddCommand(addCommand)
 }
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var blameCommand = &cobra.Command{
+	Use:          "blame <path>",
+	Short:        "Show the snapshot that last changed each line of a file",
+	Long:         "Annotate each line of a committed file with the snapshot, author and time that last changed it, following every parent of merges",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev, _ := cmd.Flags().GetString("rev")
+		porcelain, _ := cmd.Flags().GetBool("porcelain")
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		path, err := resolveDiffPath(root, args[0])
+		if err != nil {
+			ui.Println(ui.Error("Invalid file path"))
+			return err
+		}
+
+		target, err := ops.ResolveRev(db, rev)
+		if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+			ui.Println(ui.Error("Revision not found"))
+			return err
+		}
+		if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+			ui.Println(ui.Error("Commit prefix is ambiguous"))
+			return err
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to resolve revision"))
+			return err
+		}
+
+		lines, err := ops.Blame(db, target, path)
+		if err == coreerrors.ErrDataNotFound {
+			ui.Println(ui.Error(fmt.Sprintf("%s does not exist in %s", path, rev)))
+			return err
+		}
+		if err == coreerrors.ErrBinaryFile {
+			ui.Println(ui.Error(fmt.Sprintf("%s is a binary file", path)))
+			return err
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to blame file"))
+			return err
+		}
+
+		width, numWidth := 0, len(fmt.Sprint(len(lines)))
+		for _, line := range lines {
+			if n := len(blameAuthor(line.Snapshot)); n > width {
+				width = n
+			}
+		}
+
+		for i, line := range lines {
+			text := strings.TrimSuffix(line.Text, "\n")
+			if porcelain {
+				fmt.Printf("%s %d %d\n", line.Snapshot.Hash, line.OrigLine, i+1)
+				fmt.Printf("author %s\n", blameAuthor(line.Snapshot))
+				fmt.Printf("author-time %d\n", line.Snapshot.Timestamp)
+				fmt.Printf("summary %s\n", firstLine(line.Snapshot.Message))
+				fmt.Printf("\t%s\n", text)
+				continue
+			}
+
+			timestamp := time.Unix(line.Snapshot.Timestamp, 0).Format("2006-01-02 15:04:05")
+			fmt.Printf("%s (%-*s %s %*d) %s\n", line.Snapshot.Hash[:8], width, blameAuthor(line.Snapshot), timestamp, numWidth, i+1, text)
+		}
+
+		return nil
+	},
+}
+
+func blameAuthor(s *coredb.Snapshot) string {
+	if s.Author == nil {
+		return "unknown"
+	}
+	return *s.Author
+}
+
+func init() {
+	blameCommand.Flags().String("rev", "HEAD", "blame the file as of this revision")
+	blameCommand.Flags().Bool("porcelain", false, "machine-readable output for editor integrations")
+	rootCommand.AddCommand(blameCommand)
+}
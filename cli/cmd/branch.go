@@ -123,6 +123,11 @@ var createBranchCommand = &cobra.Command{
 				return err
 			}
 
+			if err := db.AppendRefLog(tx, name, nil, snapshotHash, "branch: created from "+head, reflogAuthor()); err != nil {
+				ui.Println(ui.Error("Failed to record ref log"))
+				return err
+			}
+
 			created = true
 			return nil
 		})
@@ -177,7 +182,7 @@ var deleteBranchCommand = &cobra.Command{
 				return nil
 			}
 
-			_, err = db.GetRef(tx, name)
+			ref, err := db.GetRef(tx, name)
 			if err == coreerrors.ErrRefNotFound {
 				ui.Println(ui.Error("Branch does not exist"))
 				return nil
@@ -192,6 +197,11 @@ var deleteBranchCommand = &cobra.Command{
 				return err
 			}
 
+			if err := db.AppendRefLog(tx, name, ref.SnapshotHash, nil, "branch: deleted", reflogAuthor()); err != nil {
+				ui.Println(ui.Error("Failed to record ref log"))
+				return err
+			}
+
 			deleted = true
 			return nil
 		})
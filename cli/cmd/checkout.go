@@ -3,6 +3,7 @@ package cmd
 // TODO: make workspace checkout transactional (temp dir + swap)
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/greedypanda0/kuro/cli/internal/config"
@@ -11,12 +12,13 @@ import (
 
 	coredb "github.com/greedypanda0/kuro/core/db"
 	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
 
 	"github.com/spf13/cobra"
 )
 
 var checkoutCommand = &cobra.Command{
-	Use:          "checkout [branch|commit]",
+	Use:          "checkout [branch|tag|commit]",
 	Short:        "Switch branches or restore workspace",
 	Long:         "Switch branches or restore workspace to a commit snapshot",
 	Args:         cobra.MaximumNArgs(1),
@@ -36,6 +38,7 @@ var checkoutCommand = &cobra.Command{
 		defer db.Close()
 
 		wsFlag, _ := cmd.Flags().GetBool("ws")
+		forceFlag, _ := cmd.Flags().GetBool("force")
 
 		head, err := coredb.GetConfig(db, "head")
 		if err != nil {
@@ -47,13 +50,20 @@ var checkoutCommand = &cobra.Command{
 		var snapshotHash *string
 		forceWorkspace := false
 
+		headRef, err := coredb.GetRef(db, head)
+		if err != nil {
+			ui.Println(ui.Error("Failed to resolve HEAD"))
+			return err
+		}
+
 		if len(args) == 0 {
-			ref, err := coredb.GetRef(db, head)
-			if err != nil {
-				ui.Println(ui.Error("Failed to resolve HEAD"))
-				return err
+			snapshotHash = headRef.SnapshotHash
+
+			if wsFlag && !forceFlag {
+				if err := ensureWorkspaceClean(root, db, headRef); err != nil {
+					return err
+				}
 			}
-			snapshotHash = ref.SnapshotHash
 		} else {
 			input := args[0]
 
@@ -62,16 +72,32 @@ var checkoutCommand = &cobra.Command{
 				targetBranch = input
 				snapshotHash = ref.SnapshotHash
 
+				if wsFlag && !forceFlag {
+					if err := ensureWorkspaceClean(root, db, headRef); err != nil {
+						return err
+					}
+				}
+
 				if targetBranch != head {
 					if err := coredb.SetConfig(db, "head", targetBranch); err != nil {
 						ui.Println(ui.Error("Failed to update HEAD"))
 						return err
 					}
+
+					operation := fmt.Sprintf("checkout: moving from %s to %s", head, targetBranch)
+					if err := coredb.AppendRefLog(db, "HEAD", headRef.SnapshotHash, snapshotHash, operation, reflogAuthor()); err != nil {
+						ui.Println(ui.Error("Failed to record ref log"))
+						return err
+					}
 				}
 			} else if err == coreerrors.ErrRefNotFound {
-				snapshot, err := coredb.GetSnapshot(db, input)
-				if err == coreerrors.ErrSnapshotNotFound {
-					ui.Println(ui.Error("Branch or commit not found"))
+				hash, err := ops.ResolveRev(db, input)
+				if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+					ui.Println(ui.Error("Branch, tag or commit not found"))
+					return err
+				}
+				if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+					ui.Println(ui.Error("Commit prefix is ambiguous"))
 					return err
 				}
 				if err != nil {
@@ -79,8 +105,20 @@ var checkoutCommand = &cobra.Command{
 					return err
 				}
 
-				snapshotHash = &snapshot.Hash
+				snapshotHash = &hash
 				forceWorkspace = true
+
+				if !forceFlag {
+					if err := ensureWorkspaceClean(root, db, headRef); err != nil {
+						return err
+					}
+				}
+
+				operation := fmt.Sprintf("checkout: workspace at %s", input)
+				if err := coredb.AppendRefLog(db, "HEAD", headRef.SnapshotHash, snapshotHash, operation, reflogAuthor()); err != nil {
+					ui.Println(ui.Error("Failed to record ref log"))
+					return err
+				}
 			} else {
 				ui.Println(ui.Error("Failed to resolve branch"))
 				return err
@@ -112,7 +150,67 @@ var checkoutCommand = &cobra.Command{
 	},
 }
 
+// ensureWorkspaceClean refuses to reset a workspace holding staged files,
+// tracked files that differ from HEAD or untracked files, since the reset
+// would discard them.
+func ensureWorkspaceClean(root string, db coredb.DBTX, headRef *coredb.Ref) error {
+	headFiles, err := treeAt(db, headRef.SnapshotHash)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list snapshot files"))
+		return err
+	}
+
+	dirty, err := ops.DirtyPaths(root, headFiles)
+	if err != nil {
+		ui.Println(ui.Error("Failed to inspect workspace"))
+		return err
+	}
+
+	staged, err := stagedPaths(db)
+	if err != nil {
+		ui.Println(ui.Error("Failed to get staged files"))
+		return err
+	}
+
+	// Resetting the workspace removes every file the target does not
+	// track, untracked ones included.
+	kuroIgnore, err := ops.ReadKuroIgnore(config.IgnorePathFor(root))
+	if err == coreerrors.ErrIgnoreFileNotFound {
+		kuroIgnore = []string{}
+	} else if err != nil {
+		ui.Println(ui.Error("Failed to read ignore file"))
+		return err
+	}
+	tracked := append([]string{}, staged...)
+	for _, f := range headFiles {
+		tracked = append(tracked, f.Path)
+	}
+	untracked, err := ops.UntrackedPaths(root, tracked, kuroIgnore)
+	if err != nil {
+		ui.Println(ui.Error("Failed to inspect workspace"))
+		return err
+	}
+
+	if len(dirty) == 0 && len(staged) == 0 && len(untracked) == 0 {
+		return nil
+	}
+
+	ui.Println(ui.Error("Uncommitted changes would be lost:"))
+	for _, path := range dirty {
+		ui.Println(ui.Cross(path))
+	}
+	for _, path := range staged {
+		ui.Println(ui.Cross(path + " (staged)"))
+	}
+	for _, path := range untracked {
+		ui.Println(ui.Cross(path + " (untracked)"))
+	}
+	ui.Println(ui.Simple("Save them with kuro stash push (add untracked files first), or pass --force to discard them"))
+	return errors.New("uncommitted changes")
+}
+
 func init() {
 	checkoutCommand.Flags().Bool("ws", false, "reset workspace to the target snapshot")
+	checkoutCommand.Flags().BoolP("force", "f", false, "reset the workspace even if it has uncommitted changes")
 	rootCommand.AddCommand(checkoutCommand)
 }
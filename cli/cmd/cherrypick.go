@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var cherryPickCommand = &cobra.Command{
+	Use:          "cherry-pick <rev>...",
+	Short:        "Apply the changes of existing snapshots onto the current branch",
+	Long:         "Replay the change each snapshot introduced (against its first parent) on top of HEAD as a new snapshot, keeping the original author",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		continueFlag, _ := cmd.Flags().GetBool("continue")
+		skipFlag, _ := cmd.Flags().GetBool("skip")
+		abortFlag, _ := cmd.Flags().GetBool("abort")
+
+		modes := 0
+		for _, set := range []bool{continueFlag, skipFlag, abortFlag} {
+			if set {
+				modes++
+			}
+		}
+		if modes > 1 {
+			ui.Println(ui.Error("Use only one of --continue, --skip or --abort"))
+			return errors.New("conflicting flags")
+		}
+		if modes > 0 && len(args) > 0 {
+			ui.Println(ui.Error("No revisions expected with --continue, --skip or --abort"))
+			return errors.New("unexpected revisions")
+		}
+		if modes == 0 && len(args) == 0 {
+			ui.Println(ui.Error("Revision required"))
+			return errors.New("revision required")
+		}
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		var conflicts []ops.MergeConflict
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			s, err := readSequencer(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read sequencer state"))
+				return err
+			}
+
+			if modes > 0 {
+				if s == nil || s.Op != "cherry-pick" {
+					ui.Println(ui.Error("No cherry-pick in progress"))
+					return errors.New("no cherry-pick in progress")
+				}
+				if abortFlag {
+					if err := s.abort(root, tx); err != nil {
+						return err
+					}
+					ui.Println(ui.Success("Cherry-pick aborted"))
+					return nil
+				}
+				conflicts, err = s.resume(root, tx, skipFlag)
+				return err
+			}
+
+			if s != nil {
+				return ensureNoSequencer(tx)
+			}
+
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if mergeHead != "" {
+				ui.Println(ui.Error("A merge is in progress\ncommit the result or run kuro merge --abort"))
+				return errors.New("merge in progress")
+			}
+
+			stageFiles, err := coredb.GetStageFiles(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to get staged files"))
+				return err
+			}
+			if len(stageFiles) > 0 {
+				ui.Println(ui.Error("Staged changes present, commit them before cherry-picking"))
+				return errors.New("staged changes present")
+			}
+
+			head, err := coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+
+			ref, err := coredb.GetRef(tx, head)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve HEAD"))
+				return err
+			}
+
+			s = &sequencer{Op: "cherry-pick"}
+			if ref.SnapshotHash != nil {
+				s.OrigHead = *ref.SnapshotHash
+			}
+			for _, rev := range args {
+				target, err := ops.ResolveRev(tx, rev)
+				if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+					ui.Println(ui.Error("Revision not found: " + rev))
+					return err
+				}
+				if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+					ui.Println(ui.Error("Commit prefix is ambiguous: " + rev))
+					return err
+				}
+				if err != nil {
+					ui.Println(ui.Error("Failed to resolve revision"))
+					return err
+				}
+				s.Todo = append(s.Todo, target)
+			}
+
+			conflicts, err = s.run(root, tx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(conflicts) > 0 {
+			printSequencerConflicts("cherry-pick", conflicts)
+			return errSequencerConflict
+		}
+
+		if !abortFlag {
+			ui.Println(ui.Success("Cherry-pick complete"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	cherryPickCommand.Flags().Bool("continue", false, "commit the resolved snapshot and apply the rest")
+	cherryPickCommand.Flags().Bool("skip", false, "drop the conflicted snapshot and apply the rest")
+	cherryPickCommand.Flags().Bool("abort", false, "restore the branch and workspace to before the cherry-pick")
+	rootCommand.AddCommand(cherryPickCommand)
+}
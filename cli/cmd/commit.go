@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/greedypanda0/kuro/cli/internal/config"
@@ -18,22 +16,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
-type objectFile struct {
-	Content []byte
-	Hash    string
-	Path    string
-}
-
 var commitCommand = &cobra.Command{
 	Use:          "commit",
 	Short:        "Create a commit",
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		message, _ := cmd.Flags().GetString("message")
-		if strings.TrimSpace(message) == "" {
-			ui.Println(ui.Error("Commit message required"))
-			return errors.New("commit message required")
-		}
 
 		cfg, err := config.LoadConfig()
 		if err != nil {
@@ -67,61 +55,61 @@ var commitCommand = &cobra.Command{
 				ui.Println(ui.Error("Failed to get ref"))
 				return err
 			}
+			if err := ensureNoSequencer(tx); err != nil {
+				return err
+			}
+			mergeHead, mergeMessage, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if strings.TrimSpace(message) == "" {
+				message = mergeMessage
+			}
+			if strings.TrimSpace(message) == "" {
+				ui.Println(ui.Error("Commit message required"))
+				return errors.New("commit message required")
+			}
+
 			stageFiles, err := coredb.GetStageFiles(tx)
 			if err != nil {
 				ui.Println(ui.Error("Failed to get stage files"))
 				return err
 			}
-			if len(stageFiles) == 0 {
+			if len(stageFiles) == 0 && mergeHead == "" {
 				ui.Println(ui.Error("No files staged"))
 				return nil
 			}
 
-			objectFiles := []objectFile{}
-
-			for _, file := range stageFiles {
-				absPath := filepath.Clean(filepath.Join(root, filepath.FromSlash(file.Path)))
-
-				content, err := os.ReadFile(absPath)
-				if err != nil {
-					ui.Println(ui.Error("Failed to read file"))
-					return err
-				}
-
-				objectHash := ops.Hash(content)
-
-				if err := coredb.CreateObject(tx, objectHash, content); err != nil {
-					ui.Println(ui.Error("Failed to create object"))
-					return err
-				}
-
-				objectFiles = append(objectFiles, objectFile{
-					Path:    file.Path,
-					Hash:    objectHash,
-					Content: content,
-				})
+			var parentHash *string
+			if ref != nil && ref.SnapshotHash != nil {
+				parentHash = ref.SnapshotHash
 			}
 
 			currentSnapshotFiles := []coredb.SnapshotFile{}
 
-			if ref != nil && ref.SnapshotHash != nil {
+			if parentHash != nil {
 				var err error
-				currentSnapshotFiles, err = coredb.ListSnapshotFiles(tx, *ref.SnapshotHash)
+				currentSnapshotFiles, err = coredb.ListSnapshotFiles(tx, *parentHash)
 				if err != nil {
 					ui.Println(ui.Error("Failed to list snapshot files"))
 					return err
 				}
 			}
 
-			newSnapshotFiles := []coredb.SnapshotFile{}
-			for _, file := range objectFiles {
-				newSnapshotFiles = append(newSnapshotFiles, coredb.SnapshotFile{
-					Path:       file.Path,
-					ObjectHash: file.Hash,
-				})
+			changes, err := stagedChanges(tx, root, currentSnapshotFiles, stageFiles)
+			if err != nil {
+				ui.Println(ui.Error("Failed to store file"))
+				return err
 			}
 
-			if coredb.CompareSnapshotFiles(currentSnapshotFiles, newSnapshotFiles) {
+			newSnapshotFiles, err := ops.BuildTree(tx, parentHash, changes)
+			if err != nil {
+				ui.Println(ui.Error("Failed to build snapshot tree"))
+				return err
+			}
+
+			if mergeHead == "" && coredb.CompareSnapshotFiles(currentSnapshotFiles, newSnapshotFiles) {
 				ui.Println(ui.Error("No changes detected"))
 				return nil
 			}
@@ -132,46 +120,25 @@ var commitCommand = &cobra.Command{
 				return fmt.Errorf("no name found")
 			}
 
-			var parentHash *string
-			if ref != nil && ref.SnapshotHash != nil {
-				parentHash = ref.SnapshotHash
-			}
-
-			sort.Slice(objectFiles, func(i, j int) bool {
-				return objectFiles[i].Path < objectFiles[j].Path
-			})
-
-			var builder strings.Builder
+			var parents []string
 			if parentHash != nil {
-				builder.WriteString("parent:")
-				builder.WriteString(*parentHash)
-				builder.WriteString("\n")
+				parents = append(parents, *parentHash)
 			}
-			builder.WriteString("message:")
-			builder.WriteString(message)
-
-			for _, obj := range objectFiles {
-				builder.WriteString("\npath:")
-				builder.WriteString(obj.Path)
-				builder.WriteString("\nobject:")
-				builder.WriteString(obj.Hash)
+			if mergeHead != "" {
+				parents = append(parents, mergeHead)
 			}
 
-			snapshotHash := ops.Hash([]byte(builder.String()))
-
-			if err := coredb.CreateSnapshot(tx, snapshotHash, parentHash, message, &user); err != nil {
+			snapshotHash, err := ops.CommitTree(tx, parents, message, &user, newSnapshotFiles)
+			if err != nil {
 				ui.Println(ui.Error("Failed to create snapshot"))
 				return err
 			}
 
-			for _, object := range objectFiles {
-				if err := coredb.CreateSnapshotFile(tx, snapshotHash, object.Path, object.Hash); err != nil {
-					ui.Println(ui.Error("Failed to create snapshot files"))
-					return err
-				}
+			operation := "commit: " + firstLine(message)
+			if mergeHead != "" {
+				operation = "commit (merge): " + firstLine(message)
 			}
-
-			if err := coredb.UpdateRef(tx, head, &snapshotHash); err != nil {
+			if err := coredb.UpdateRef(tx, head, &snapshotHash, operation, &user); err != nil {
 				ui.Println(ui.Error("Failed to update head ref"))
 				return err
 			}
@@ -181,6 +148,13 @@ var commitCommand = &cobra.Command{
 				return err
 			}
 
+			if mergeHead != "" {
+				if err := clearMergeState(tx); err != nil {
+					ui.Println(ui.Error("Failed to clear merge state"))
+					return err
+				}
+			}
+
 			done = true
 			return nil
 		})
@@ -197,6 +171,45 @@ var commitCommand = &cobra.Command{
 	},
 }
 
+// stagedChanges turns the stage into tree changes against headFiles.
+// Paths staged without content are stored from the workspace, and deleted
+// when missing there.
+func stagedChanges(tx coredb.DBTX, root string, headFiles []coredb.SnapshotFile, stageFiles []coredb.Stage) ([]ops.Change, error) {
+	cache, err := ops.LoadStatCache(tx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[string]string, len(headFiles))
+	for _, f := range headFiles {
+		previous[f.Path] = f.ObjectHash
+	}
+
+	changes := make([]ops.Change, 0, len(stageFiles))
+	for _, file := range stageFiles {
+		if file.Deleted {
+			changes = append(changes, ops.Change{Path: file.Path, Deleted: true})
+			continue
+		}
+		if file.ObjectHash != "" {
+			changes = append(changes, ops.Change{Path: file.Path, ObjectHash: file.ObjectHash})
+			continue
+		}
+
+		objectHash, err := cache.Store(tx, file.Path, previous[file.Path])
+		if os.IsNotExist(err) {
+			changes = append(changes, ops.Change{Path: file.Path, Deleted: true})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, ops.Change{Path: file.Path, ObjectHash: objectHash})
+	}
+
+	return changes, cache.Save(tx)
+}
+
 func init() {
 	commitCommand.Flags().StringP("message", "m", "", "commit message")
 	rootCommand.AddCommand(commitCommand)
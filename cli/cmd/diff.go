@@ -1,30 +1,62 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/greedypanda0/kuro/cli/internal/config"
 	"github.com/greedypanda0/kuro/cli/internal/ui"
 
 	coredb "github.com/greedypanda0/kuro/core/db"
-	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
 
-	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 )
 
 var diffCommand = &cobra.Command{
-	Use:          "diff",
-	Short:        "Show staged file changes",
-	Long:         "Show differences between staged files and the last commit",
+	Use:   "diff [rev [rev] | rev..rev] [-- path...]",
+	Short: "Show changes between revisions, the stage and the workspace",
+	Long: `Show changes as unified diffs.
+
+  kuro diff                 workspace changes not yet staged
+  kuro diff --cached [rev]  staged changes against HEAD (or rev)
+  kuro diff <rev>           workspace against rev
+  kuro diff <a> <b>         rev a against rev b (also a..b)
+
+Paths after -- limit the output to those files or directories.`,
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cached, _ := cmd.Flags().GetBool("cached")
+		fileFlag, _ := cmd.Flags().GetString("file")
+		nameStatus, _ := cmd.Flags().GetBool("name-status")
+
+		revs, pathArgs := args, []string(nil)
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			revs, pathArgs = args[:dash], args[dash:]
+		}
+		if len(revs) == 1 {
+			if from, to, ok := strings.Cut(revs[0], ".."); ok {
+				revs = []string{from, to}
+				for i := range revs {
+					if revs[i] == "" {
+						revs[i] = "HEAD"
+					}
+				}
+			}
+		}
+		if len(revs) > 2 || (cached && len(revs) > 1) {
+			ui.Println(ui.Error("Too many revisions"))
+			return errors.New("too many revisions")
+		}
+		if fileFlag != "" {
+			pathArgs = append(pathArgs, fileFlag)
+		}
+
 		root, err := config.RepoRoot()
 		if err != nil {
 			ui.Println(ui.Error("Repository not initialized"))
@@ -38,111 +70,119 @@ var diffCommand = &cobra.Command{
 		}
 		defer db.Close()
 
-		head, err := coredb.GetConfig(db, "head")
+		paths := make([]string, 0, len(pathArgs))
+		for _, p := range pathArgs {
+			if abs, err := filepath.Abs(p); err == nil && abs == root {
+				continue
+			}
+			rel, err := resolveDiffPath(root, p)
+			if err != nil {
+				ui.Println(ui.Error("Invalid file path"))
+				return err
+			}
+			paths = append(paths, rel)
+		}
+
+		cache, err := ops.LoadStatCache(db, root)
 		if err != nil {
-			ui.Println(ui.Error("Failed to get HEAD"))
+			ui.Println(ui.Error("Failed to read stat cache"))
 			return err
 		}
+		defer saveStatCache(db, cache)
 
-		ref, err := coredb.GetRef(db, head)
+		hashes := make([]string, len(revs))
+		for i, rev := range revs {
+			if hashes[i], err = resolveLogRev(db, rev); err != nil {
+				return err
+			}
+		}
+
+		var d *ops.TreeDiff
+		switch {
+		case len(revs) == 2:
+			d, err = ops.DiffSnapshots(db, hashes[0], hashes[1])
+		case cached:
+			var base *string
+			if len(hashes) == 1 {
+				base = &hashes[0]
+			}
+			d, err = ops.DiffStaged(db, cache, base)
+		case len(revs) == 1:
+			d, err = ops.DiffWorkspace(db, cache, hashes[0])
+		default:
+			d, err = ops.DiffUnstaged(db, cache)
+		}
 		if err != nil {
-			ui.Println(ui.Error("Failed to get ref"))
+			ui.Println(ui.Error("Failed to compare trees"))
 			return err
 		}
 
-		stageFiles, err := coredb.GetStageFiles(db)
+		changes, err := ops.DetectRenames(d.Changes, d.OldTree, d.Old, d.New, renameOptions(cmd))
 		if err != nil {
-			ui.Println(ui.Error("Failed to get staged files"))
+			ui.Println(ui.Error("Failed to detect renames"))
 			return err
 		}
-		if len(stageFiles) == 0 {
-			ui.Println(ui.Simple("No files staged"))
+		changes = ops.FilterChanges(changes, paths)
+
+		if nameStatus {
+			printNameStatus(changes)
 			return nil
 		}
 
-		paths := make([]string, 0, len(stageFiles))
-		stagedSet := make(map[string]struct{}, len(stageFiles))
-		for _, f := range stageFiles {
-			paths = append(paths, f.Path)
-			stagedSet[f.Path] = struct{}{}
-		}
-		sort.Strings(paths)
+		ui.Println(ui.Header("Diff"))
 
-		fileFlag, _ := cmd.Flags().GetString("file")
-		if fileFlag != "" {
-			rel, err := resolveDiffPath(root, fileFlag)
-			if err != nil {
-				ui.Println(ui.Error("Invalid file path"))
-				return err
-			}
-			if _, ok := stagedSet[rel]; !ok {
-				ui.Println(ui.Error("File is not staged"))
-				return nil
-			}
-			paths = []string{rel}
+		if len(changes) == 0 {
+			ui.Println(ui.Simple("No changes"))
+			return nil
 		}
 
-		var snapshotHash string
-		if ref != nil && ref.SnapshotHash != nil {
-			snapshotHash = *ref.SnapshotHash
+		if err := ops.WriteDiff(os.Stdout, changes, d.Old, d.New); err != nil {
+			ui.Println(ui.Error("Failed to render diff"))
+			return err
 		}
 
-		ui.Println(ui.Header("Diff"))
-
-		anyDiff := false
-
-		for _, relPath := range paths {
-			absPath := filepath.Join(root, filepath.FromSlash(relPath))
-			newContent, err := os.ReadFile(absPath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					newContent = nil
-				} else {
-					ui.Println(ui.Error(fmt.Sprintf("Failed to read %s", relPath)))
-					return err
-				}
-			}
-
-			var oldContent []byte
-			if snapshotHash != "" {
-				sf, err := coredb.GetSnapshotFile(db, snapshotHash, relPath)
-				if err != nil {
-					if err != coreerrors.ErrDataNotFound {
-						ui.Println(ui.Error("Failed to read snapshot file"))
-						return err
-					}
-				} else {
-					obj, err := coredb.GetObject(db, sf.ObjectHash)
-					if err != nil {
-						ui.Println(ui.Error("Failed to read object"))
-						return err
-					}
-					oldContent = obj.Content
-				}
-			}
-
-			if bytes.Equal(oldContent, newContent) {
-				continue
-			}
+		return nil
+	},
+}
 
-			anyDiff = true
+// saveStatCache records the file hashes computed through cache. Failing
+// to do so only slows down the next command, so it is not an error.
+func saveStatCache(db *sql.DB, cache *ops.StatCache) {
+	err := coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+		return cache.Save(tx)
+	})
+	if err != nil {
+		ui.Println(ui.Warn("Failed to update stat cache"))
+	}
+}
 
-			if !utf8.Valid(oldContent) || !utf8.Valid(newContent) {
-				fmt.Printf("diff --kuro %s\n", relPath)
-				fmt.Printf("Binary files a/%s and b/%s differ\n\n", relPath, relPath)
-				continue
-			}
+// addRenameFlags registers the rename and copy detection flags shared by
+// the commands that compare trees.
+func addRenameFlags(cmd *cobra.Command, copies bool) {
+	cmd.Flags().IntP("find-renames", "M", ops.DefaultRenameThreshold, "minimum similarity percentage for renames and copies (101 for identical content only)")
+	if copies {
+		cmd.Flags().BoolP("find-copies", "C", false, "also detect copies of existing files")
+	}
+}
 
-			diffText := renderSimpleDiff(relPath, oldContent, newContent)
-			fmt.Print(diffText)
-		}
+func renameOptions(cmd *cobra.Command) ops.RenameOptions {
+	threshold, _ := cmd.Flags().GetInt("find-renames")
+	copies, _ := cmd.Flags().GetBool("find-copies")
+	return ops.RenameOptions{Threshold: threshold, Copies: copies}
+}
 
-		if !anyDiff {
-			ui.Println(ui.Simple("No changes"))
-		}
+// changeLabel names a change, showing the source of renames and copies.
+func changeLabel(c ops.FileChange) string {
+	if c.OldPath != "" {
+		return c.OldPath + " -> " + c.Path
+	}
+	return c.Path
+}
 
-		return nil
-	},
+func printNameStatus(changes []ops.FileChange) {
+	for _, c := range changes {
+		fmt.Printf("%s\t%s\n", c.Status, changeLabel(c))
+	}
 }
 
 func resolveDiffPath(root, input string) (string, error) {
@@ -167,39 +207,10 @@ func resolveDiffPath(root, input string) (string, error) {
 	return rel, nil
 }
 
-func renderSimpleDiff(path string, oldContent, newContent []byte) string {
-	diff := difflib.UnifiedDiff{
-		A:        difflib.SplitLines(normalizeDiffInput(oldContent)),
-		B:        difflib.SplitLines(normalizeDiffInput(newContent)),
-		FromFile: "a/" + path,
-		ToFile:   "b/" + path,
-		Context:  3,
-	}
-
-	text, err := difflib.GetUnifiedDiffString(diff)
-	if err != nil {
-		return fmt.Sprintf("diff --kuro %s\n--- a/%s\n+++ b/%s\n\n", path, path, path)
-	}
-
-	if text != "" && !strings.HasSuffix(text, "\n") {
-		text += "\n"
-	}
-
-	return text + "\n"
-}
-
-func normalizeDiffInput(b []byte) string {
-	if len(b) == 0 {
-		return ""
-	}
-	s := strings.ReplaceAll(string(b), "\r\n", "\n")
-	if !strings.HasSuffix(s, "\n") {
-		s += "\n"
-	}
-	return s
-}
-
 func init() {
-	diffCommand.Flags().StringP("file", "f", "", "diff a specific staged file")
+	diffCommand.Flags().Bool("cached", false, "show staged changes instead of unstaged ones")
+	diffCommand.Flags().StringP("file", "f", "", "limit the diff to a file or directory")
+	diffCommand.Flags().Bool("name-status", false, "show only the status and path of changed files")
+	addRenameFlags(diffCommand, true)
 	rootCommand.AddCommand(diffCommand)
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var fsckCommand = &cobra.Command{
+	Use:          "fsck",
+	Short:        "Verify repository integrity",
+	Long:         "Check objects, snapshots, parents, refs and snapshot files for corruption; exits non-zero when problems are found",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		ui.Println(ui.Step("Checking repository..."))
+
+		issues, err := ops.Verify(db)
+		if err != nil {
+			ui.Println(ui.Error("Failed to verify repository"))
+			return err
+		}
+
+		if len(issues) == 0 {
+			ui.Println(ui.Success("No problems found"))
+			return nil
+		}
+
+		for _, issue := range issues {
+			ui.Println(ui.Cross(issue.String()))
+		}
+		ui.Println(ui.Error(fmt.Sprintf("Found %d problem(s)", len(issues))))
+		return errors.New("repository integrity check failed")
+	},
+}
+
+func init() {
+	rootCommand.AddCommand(fsckCommand)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var gcCommand = &cobra.Command{
+	Use:          "gc",
+	Short:        "Remove unreachable objects and snapshots",
+	Long:         "Remove objects and snapshots no longer reachable from any ref, then compact the database",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		grace, _ := cmd.Flags().GetDuration("grace")
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		var report *ops.GCReport
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+
+			opts := ops.GCOptions{
+				DryRun: dryRun,
+				Cutoff: time.Now().Add(-grace),
+			}
+			if mergeHead != "" {
+				opts.Roots = append(opts.Roots, mergeHead)
+			}
+
+			report, err = ops.CollectGarbage(tx, opts)
+			if err != nil {
+				ui.Println(ui.Error("Failed to collect garbage"))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			for _, hash := range report.Snapshots {
+				ui.Println(ui.Bullet("snapshot " + hash))
+			}
+			for _, hash := range report.Objects {
+				ui.Println(ui.Bullet("object " + hash))
+			}
+			ui.Println(ui.Step(fmt.Sprintf(
+				"Would remove %d snapshot(s) and %d object(s), reclaiming %s",
+				len(report.Snapshots), len(report.Objects), formatBytes(report.ReclaimedBytes),
+			)))
+			return nil
+		}
+
+		ui.Println(ui.Step("Compacting database..."))
+		if err := coredb.Vacuum(db); err != nil {
+			ui.Println(ui.Error("Failed to vacuum database"))
+			return err
+		}
+
+		ui.Println(ui.Success(fmt.Sprintf(
+			"Removed %d snapshot(s) and %d object(s), reclaimed %s",
+			len(report.Snapshots), len(report.Objects), formatBytes(report.ReclaimedBytes),
+		)))
+		return nil
+	},
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	gcCommand.Flags().Bool("dry-run", false, "report what would be removed without deleting")
+	gcCommand.Flags().Duration("grace", ops.DefaultGracePeriod, "keep unreachable data newer than this")
+	rootCommand.AddCommand(gcCommand)
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"sort"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/greedypanda0/kuro/cli/internal/config"
@@ -10,14 +13,16 @@ import (
 
 	coredb "github.com/greedypanda0/kuro/core/db"
 	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
 
 	"github.com/spf13/cobra"
 )
 
 var logsCommand = &cobra.Command{
-	Use:          "logs",
+	Use:          "logs [rev | A..B]",
 	Short:        "Show commit logs",
-	Long:         "Show commit logs from newest to oldest",
+	Long:         "Show commit logs from newest to oldest, starting at HEAD, a branch, a tag or a commit, optionally limited to a range and filtered by time, author, message or path",
+	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		root, err := config.RepoRoot()
@@ -34,6 +39,21 @@ var logsCommand = &cobra.Command{
 		defer db.Close()
 
 		branch, _ := cmd.Flags().GetString("branch")
+		limit, _ := cmd.Flags().GetInt("max-count")
+		since, _ := cmd.Flags().GetString("since")
+		until, _ := cmd.Flags().GetString("until")
+		author, _ := cmd.Flags().GetString("author")
+		grep, _ := cmd.Flags().GetString("grep")
+		pathFlag, _ := cmd.Flags().GetString("path")
+		oneline, _ := cmd.Flags().GetBool("oneline")
+		follow, _ := cmd.Flags().GetBool("follow")
+		graph, _ := cmd.Flags().GetBool("graph")
+		all, _ := cmd.Flags().GetBool("all")
+
+		if (graph || all) && (len(args) > 0 || since != "" || until != "" || author != "" || grep != "" || pathFlag != "") {
+			ui.Println(ui.Error("--graph and --all cannot be combined with ranges or filters"))
+			return errors.New("unsupported flag combination")
+		}
 
 		head, err := coredb.GetConfig(db, "head")
 		if err != nil {
@@ -41,66 +61,329 @@ var logsCommand = &cobra.Command{
 			return err
 		}
 
-		target := head
-		if branch != "" {
-			target = branch
+		if graph || all {
+			return printGraph(db, head, branch, all, graph, oneline, limit)
 		}
 
-		ref, err := coredb.GetRef(db, target)
-		if err == coreerrors.ErrRefNotFound {
-			ui.Println(ui.Error("Branch not found"))
-			return err
+		if follow && (pathFlag == "" || len(args) > 0 || since != "" || until != "" || author != "" || grep != "") {
+			ui.Println(ui.Error("--follow needs --path and cannot be combined with ranges or other filters"))
+			return errors.New("unsupported flag combination")
 		}
-		if err != nil {
-			ui.Println(ui.Error("Failed to resolve branch"))
-			return err
+
+		var (
+			tip     *string
+			exclude *string
+			title   string
+		)
+
+		if len(args) == 1 {
+			from, to, isRange := strings.Cut(args[0], "..")
+			if !isRange {
+				to = args[0]
+			}
+			if to == "" {
+				to = "HEAD"
+			}
+
+			hash, err := resolveLogRev(db, to)
+			if err != nil {
+				return err
+			}
+			tip = &hash
+			title = fmt.Sprintf("Revision %s", args[0])
+
+			if isRange {
+				if from == "" {
+					from = "HEAD"
+				}
+				hash, err := resolveLogRev(db, from)
+				if err != nil {
+					return err
+				}
+				exclude = &hash
+				title = fmt.Sprintf("Range %s", args[0])
+			}
+		} else {
+			target := head
+			if branch != "" {
+				target = branch
+			}
+
+			ref, err := coredb.GetRef(db, target)
+			if err == coreerrors.ErrRefNotFound {
+				ui.Println(ui.Error("Branch not found"))
+				return err
+			}
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve branch"))
+				return err
+			}
+			tip = ref.SnapshotHash
+			title = fmt.Sprintf("Branch %s", ref.Name)
 		}
 
-		if ref.SnapshotHash == nil {
+		if tip == nil {
 			ui.Println(ui.Simple("No commits yet"))
 			return nil
 		}
 
-		ui.Println(ui.Header("Commits"))
-		ui.Println(ui.Header(fmt.Sprintf("Branch %s", ref.Name)))
+		query := coredb.HistoryQuery{
+			Tip:     *tip,
+			Exclude: exclude,
+			Author:  author,
+			Grep:    grep,
+			Limit:   limit,
+		}
 
-		var snapshots []coredb.Snapshot
-		current := *ref.SnapshotHash
-		for {
-			snapshot, err := coredb.GetSnapshot(db, current)
-			if err == coreerrors.ErrSnapshotNotFound {
-				ui.Println(ui.Warn("Commit history is incomplete"))
-				return nil
+		now := time.Now()
+		if since != "" {
+			query.Since, err = parseLogTime(since, now)
+			if err != nil {
+				ui.Println(ui.Error("Invalid --since time"))
+				return err
 			}
+		}
+		if until != "" {
+			query.Until, err = parseLogTime(until, now)
+			if err != nil {
+				ui.Println(ui.Error("Invalid --until time"))
+				return err
+			}
+		}
+
+		if pathFlag != "" {
+			query.Path, err = resolveDiffPath(root, pathFlag)
 			if err != nil {
-				ui.Println(ui.Error("Failed to read snapshot"))
+				ui.Println(ui.Error("Invalid file path"))
 				return err
 			}
+		}
 
-			snapshots = append(snapshots, *snapshot)
+		var snapshots []coredb.Snapshot
+		if follow {
+			snapshots, err = ops.FollowHistory(db, *tip, query.Path, renameOptions(cmd))
+			if limit > 0 && len(snapshots) > limit {
+				snapshots = snapshots[:limit]
+			}
+		} else {
+			snapshots, err = coredb.QueryHistory(db, query)
+		}
+		if err == coreerrors.ErrSnapshotNotFound {
+			ui.Println(ui.Warn("Commit history is incomplete, run kuro fsck for details"))
+			return nil
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to read history"))
+			return err
+		}
 
-			if snapshot.ParentHash == nil {
-				break
+		if oneline {
+			for _, snapshot := range snapshots {
+				fmt.Printf("%s %s\n", snapshot.Hash[:8], firstLine(snapshot.Message))
 			}
-			current = *snapshot.ParentHash
+			return nil
 		}
 
-		sort.Slice(snapshots, func(i, j int) bool {
-			return snapshots[i].Timestamp < snapshots[j].Timestamp
-		})
+		ui.Println(ui.Header("Commits"))
+		ui.Println(ui.Header(title))
+
+		if len(snapshots) == 0 {
+			ui.Println(ui.Simple("No matching commits"))
+			return nil
+		}
 
 		for _, snapshot := range snapshots {
-			timestamp := time.Unix(snapshot.Timestamp, 0).
+			meta := time.Unix(snapshot.Timestamp, 0).
 				Format("Mon Jan 2 15:04:05 2006")
+			if snapshot.Author != nil {
+				meta += "  " + *snapshot.Author
+			}
 			ui.Println(ui.Step(fmt.Sprintf("%s  %s", snapshot.Hash, snapshot.Message)))
-			ui.Println(ui.Muted.Render(fmt.Sprintf("  %s", timestamp)))
+			ui.Println(ui.Muted.Render(fmt.Sprintf("  %s", meta)))
 		}
 
 		return nil
 	},
 }
 
+// printGraph prints the history of every ref (or of one branch) in
+// topological order, optionally with the lanes of the commit graph.
+func printGraph(db coredb.DBTX, head, branch string, all, graph, oneline bool, limit int) error {
+	refs, err := coredb.ListRefs(db)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list refs"))
+		return err
+	}
+	tags, err := coredb.ListTags(db)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list tags"))
+		return err
+	}
+
+	target := head
+	if branch != "" {
+		target = branch
+	}
+
+	var tips []string
+	labels := map[string][]string{}
+	found := false
+	for _, ref := range refs {
+		if ref.SnapshotHash == nil {
+			if ref.Name == target {
+				found = true
+			}
+			continue
+		}
+		name := ref.Name
+		if name == head {
+			name = "HEAD -> " + name
+		}
+		labels[*ref.SnapshotHash] = append(labels[*ref.SnapshotHash], name)
+		if all || ref.Name == target {
+			tips = append(tips, *ref.SnapshotHash)
+			found = found || ref.Name == target
+		}
+	}
+	for _, tag := range tags {
+		labels[tag.SnapshotHash] = append(labels[tag.SnapshotHash], "tag: "+tag.Name)
+		if all {
+			tips = append(tips, tag.SnapshotHash)
+		}
+	}
+	if !all && !found {
+		ui.Println(ui.Error("Branch not found"))
+		return coreerrors.ErrRefNotFound
+	}
+
+	if len(tips) == 0 {
+		ui.Println(ui.Simple("No commits yet"))
+		return nil
+	}
+
+	lines, err := ops.Graph(db, tips)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read history"))
+		return err
+	}
+
+	if !graph && !oneline {
+		ui.Println(ui.Header("Commits"))
+		ui.Println(ui.Header("All refs"))
+	}
+
+	shown := 0
+	for _, line := range lines {
+		if limit > 0 && shown == limit {
+			break
+		}
+		if line.Snapshot == nil {
+			if graph {
+				fmt.Println(ui.Graph(line.Prefix))
+			}
+			continue
+		}
+		shown++
+
+		snapshot := line.Snapshot
+		label := ui.RefLabel(labels[snapshot.Hash])
+		if label != "" {
+			label += " "
+		}
+
+		if graph || oneline {
+			prefix := ""
+			if graph {
+				prefix = ui.Graph(line.Prefix) + " "
+			}
+			fmt.Printf("%s%s %s%s\n", prefix, snapshot.Hash[:8], label, firstLine(snapshot.Message))
+			continue
+		}
+
+		meta := time.Unix(snapshot.Timestamp, 0).
+			Format("Mon Jan 2 15:04:05 2006")
+		if snapshot.Author != nil {
+			meta += "  " + *snapshot.Author
+		}
+		ui.Println(ui.Step(fmt.Sprintf("%s  %s%s", snapshot.Hash, label, snapshot.Message)))
+		ui.Println(ui.Muted.Render(fmt.Sprintf("  %s", meta)))
+	}
+
+	return nil
+}
+
+func resolveLogRev(db coredb.DBTX, rev string) (string, error) {
+	hash, err := ops.ResolveRev(db, rev)
+	if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+		ui.Println(ui.Error("Revision not found: " + rev))
+		return "", err
+	}
+	if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+		ui.Println(ui.Error("Commit prefix is ambiguous: " + rev))
+		return "", err
+	}
+	if err != nil {
+		ui.Println(ui.Error("Failed to resolve revision"))
+		return "", err
+	}
+	return hash, nil
+}
+
+var relativeTimePattern = regexp.MustCompile(`^(\d+)[ .]?(minute|hour|day|week|month|year)s?( ago)?$`)
+
+// parseLogTime accepts an absolute date (2006-01-02, optionally with
+// 15:04 or 15:04:05, or RFC 3339) or a relative one such as "2 weeks ago"
+// or "3.days", and returns it in Unix seconds.
+func parseLogTime(value string, now time.Time) (int64, error) {
+	value = strings.TrimSpace(value)
+
+	if m := relativeTimePattern.FindStringSubmatch(value); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, err
+		}
+		var t time.Time
+		switch m[2] {
+		case "minute":
+			t = now.Add(-time.Duration(n) * time.Minute)
+		case "hour":
+			t = now.Add(-time.Duration(n) * time.Hour)
+		case "day":
+			t = now.AddDate(0, 0, -n)
+		case "week":
+			t = now.AddDate(0, 0, -7*n)
+		case "month":
+			t = now.AddDate(0, -n, 0)
+		case "year":
+			t = now.AddDate(-n, 0, 0)
+		}
+		return t.Unix(), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognised time %q", value)
+}
+
 func init() {
 	logsCommand.Flags().StringP("branch", "b", "", "show logs for a branch")
+	logsCommand.Flags().IntP("max-count", "n", 0, "show at most this many commits")
+	logsCommand.Flags().String("since", "", "show commits newer than a date or relative time (e.g. 2 weeks ago)")
+	logsCommand.Flags().String("until", "", "show commits older than a date or relative time")
+	logsCommand.Flags().String("author", "", "show commits whose author contains this text")
+	logsCommand.Flags().String("grep", "", "show commits whose message contains this text")
+	logsCommand.Flags().String("path", "", "show commits that changed this file or directory")
+	logsCommand.Flags().Bool("follow", false, "with --path, keep following the file across renames")
+	addRenameFlags(logsCommand, false)
+	logsCommand.Flags().Bool("oneline", false, "show each commit as a short hash and subject")
+	logsCommand.Flags().Bool("graph", false, "draw the commit graph, one commit per line")
+	logsCommand.Flags().Bool("all", false, "show the history of every branch and tag")
 	rootCommand.AddCommand(logsCommand)
 }
@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	mergeHeadKey    = "merge_head"
+	mergeMessageKey = "merge_message"
+)
+
+var errMergeConflict = errors.New("merge conflict")
+
+var mergeCommand = &cobra.Command{
+	Use:          "merge <branch>",
+	Short:        "Merge a branch into the current branch",
+	Long:         "Three-way merge another branch into the current branch, fast-forwarding when possible",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		abortFlag, _ := cmd.Flags().GetBool("abort")
+		message, _ := cmd.Flags().GetString("message")
+
+		if !abortFlag && len(args) != 1 {
+			ui.Println(ui.Error("Branch name required"))
+			return errors.New("branch name required")
+		}
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		if abortFlag {
+			return coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+				return abortMerge(root, tx)
+			})
+		}
+
+		branch := args[0]
+		var conflicts []ops.MergeConflict
+		status := ""
+
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			if err := ensureNoSequencer(tx); err != nil {
+				return err
+			}
+
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if mergeHead != "" {
+				ui.Println(ui.Error("A merge is already in progress\ncommit the result or run kuro merge --abort"))
+				return errors.New("merge in progress")
+			}
+
+			head, err := coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+			if branch == head {
+				ui.Println(ui.Error("Cannot merge a branch into itself"))
+				return errors.New("cannot merge branch into itself")
+			}
+
+			ref, err := coredb.GetRef(tx, head)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve HEAD"))
+				return err
+			}
+
+			target, err := coredb.GetRef(tx, branch)
+			if err == coreerrors.ErrRefNotFound {
+				ui.Println(ui.Error("Branch not found"))
+				return err
+			}
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve branch"))
+				return err
+			}
+			if target.SnapshotHash == nil {
+				status = "Nothing to merge"
+				return nil
+			}
+			theirs := *target.SnapshotHash
+
+			stageFiles, err := coredb.GetStageFiles(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to get staged files"))
+				return err
+			}
+			if len(stageFiles) > 0 {
+				ui.Println(ui.Error("Staged changes present, commit them before merging"))
+				return errors.New("staged changes present")
+			}
+
+			oursFiles := []coredb.SnapshotFile{}
+			if ref.SnapshotHash != nil {
+				oursFiles, err = coredb.ListSnapshotFiles(tx, *ref.SnapshotHash)
+				if err != nil {
+					ui.Println(ui.Error("Failed to list snapshot files"))
+					return err
+				}
+			}
+
+			theirsFiles, err := coredb.ListSnapshotFiles(tx, theirs)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			var base *string
+			if ref.SnapshotHash != nil {
+				base, err = ops.MergeBase(tx, *ref.SnapshotHash, theirs)
+				if err != nil {
+					ui.Println(ui.Error("Failed to compute merge base"))
+					return err
+				}
+				if base != nil && *base == theirs {
+					status = "Already up to date"
+					return nil
+				}
+			}
+
+			if ref.SnapshotHash == nil || (base != nil && *base == *ref.SnapshotHash) {
+				if err := ensureWorkspaceSafe(root, oursFiles, theirsFiles); err != nil {
+					return err
+				}
+				if err := coredb.UpdateRef(tx, head, &theirs, "merge "+branch+": fast-forward", reflogAuthor()); err != nil {
+					ui.Println(ui.Error("Failed to update ref"))
+					return err
+				}
+				if err := repo.ApplyTree(root, tx, oursFiles, theirsFiles); err != nil {
+					ui.Println(ui.Error("Failed to update workspace"))
+					return err
+				}
+				status = fmt.Sprintf("Fast-forward to %s", theirs)
+				return nil
+			}
+
+			baseFiles := []coredb.SnapshotFile{}
+			if base != nil {
+				baseFiles, err = coredb.ListSnapshotFiles(tx, *base)
+				if err != nil {
+					ui.Println(ui.Error("Failed to list snapshot files"))
+					return err
+				}
+			}
+
+			result, err := ops.MergeTrees(tx, baseFiles, oursFiles, theirsFiles, "HEAD", branch)
+			if err != nil {
+				ui.Println(ui.Error("Failed to merge trees"))
+				return err
+			}
+
+			if err := ensureWorkspaceSafe(root, oursFiles, result.Files); err != nil {
+				return err
+			}
+			if err := ensureConflictsSafe(root, oursFiles, result.Conflicts); err != nil {
+				return err
+			}
+
+			if message == "" {
+				message = fmt.Sprintf("Merge branch '%s' into %s", branch, head)
+			}
+
+			if len(result.Conflicts) > 0 {
+				conflicts = result.Conflicts
+				return recordConflicts(root, tx, oursFiles, result, theirs, message)
+			}
+
+			user, err := authorName()
+			if err != nil {
+				return err
+			}
+
+			snapshotHash, err := ops.CommitTree(tx, []string{*ref.SnapshotHash, theirs}, message, &user, result.Files)
+			if err != nil {
+				ui.Println(ui.Error("Failed to create snapshot"))
+				return err
+			}
+
+			if err := coredb.UpdateRef(tx, head, &snapshotHash, "merge "+branch+": "+firstLine(message), &user); err != nil {
+				ui.Println(ui.Error("Failed to update ref"))
+				return err
+			}
+
+			if err := repo.ApplyTree(root, tx, oursFiles, result.Files); err != nil {
+				ui.Println(ui.Error("Failed to update workspace"))
+				return err
+			}
+
+			status = fmt.Sprintf("Merged %s into %s", branch, head)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(conflicts) > 0 {
+			ui.Println(ui.Warn("Automatic merge failed, fix conflicts then add and commit the result"))
+			for _, c := range conflicts {
+				ui.Println(ui.Cross(c.Path))
+			}
+			return errMergeConflict
+		}
+
+		ui.Println(ui.Success(status))
+		return nil
+	},
+}
+
+// recordConflicts writes a conflicted merge into the workspace, stages the
+// cleanly merged paths and remembers the other parent for the next commit.
+func recordConflicts(root string, tx coredb.DBTX, oursFiles []coredb.SnapshotFile, result *ops.MergeResult, theirs, message string) error {
+	if err := repo.ApplyTree(root, tx, oursFiles, result.Files); err != nil {
+		ui.Println(ui.Error("Failed to update workspace"))
+		return err
+	}
+
+	conflicted := make(map[string]struct{}, len(result.Conflicts))
+	for _, c := range result.Conflicts {
+		conflicted[c.Path] = struct{}{}
+		if c.Content == nil {
+			continue
+		}
+		if err := repo.WriteFile(root, c.Path, c.Content); err != nil {
+			ui.Println(ui.Error("Failed to write conflicted file"))
+			return err
+		}
+	}
+
+	oursMap := make(map[string]string, len(oursFiles))
+	for _, f := range oursFiles {
+		oursMap[f.Path] = f.ObjectHash
+	}
+	resultMap := make(map[string]struct{}, len(result.Files))
+	for _, f := range result.Files {
+		resultMap[f.Path] = struct{}{}
+		if _, ok := conflicted[f.Path]; ok || oursMap[f.Path] == f.ObjectHash {
+			continue
+		}
+		if err := coredb.AddStageFile(tx, f.Path, f.ObjectHash); err != nil {
+			ui.Println(ui.Error("Failed to stage merged file"))
+			return err
+		}
+	}
+	for path := range oursMap {
+		if _, ok := resultMap[path]; ok {
+			continue
+		}
+		if err := coredb.StageDeletion(tx, path); err != nil {
+			ui.Println(ui.Error("Failed to stage merged file"))
+			return err
+		}
+	}
+
+	if err := coredb.SetConfig(tx, mergeHeadKey, theirs); err != nil {
+		ui.Println(ui.Error("Failed to record merge state"))
+		return err
+	}
+	if err := coredb.SetConfig(tx, mergeMessageKey, message); err != nil {
+		ui.Println(ui.Error("Failed to record merge state"))
+		return err
+	}
+
+	return nil
+}
+
+func abortMerge(root string, tx coredb.DBTX) error {
+	mergeHead, _, err := readMergeState(tx)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read merge state"))
+		return err
+	}
+	if mergeHead == "" {
+		ui.Println(ui.Error("No merge in progress"))
+		return errors.New("no merge in progress")
+	}
+
+	head, err := coredb.GetConfig(tx, "head")
+	if err != nil {
+		ui.Println(ui.Error("Failed to read HEAD"))
+		return err
+	}
+	ref, err := coredb.GetRef(tx, head)
+	if err != nil {
+		ui.Println(ui.Error("Failed to resolve HEAD"))
+		return err
+	}
+
+	oursFiles := []coredb.SnapshotFile{}
+	if ref.SnapshotHash != nil {
+		oursFiles, err = coredb.ListSnapshotFiles(tx, *ref.SnapshotHash)
+		if err != nil {
+			ui.Println(ui.Error("Failed to list snapshot files"))
+			return err
+		}
+	}
+	theirsFiles, err := coredb.ListSnapshotFiles(tx, mergeHead)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list snapshot files"))
+		return err
+	}
+
+	// Only paths that differ between the two sides can have been touched.
+	if err := repo.ApplyTree(root, tx, theirsFiles, oursFiles); err != nil {
+		ui.Println(ui.Error("Failed to restore workspace"))
+		return err
+	}
+
+	if err := coredb.ClearStage(tx); err != nil {
+		ui.Println(ui.Error("Failed to clear stage"))
+		return err
+	}
+
+	if err := clearMergeState(tx); err != nil {
+		ui.Println(ui.Error("Failed to clear merge state"))
+		return err
+	}
+
+	ui.Println(ui.Success("Merge aborted"))
+	return nil
+}
+
+func ensureWorkspaceSafe(root string, from, to []coredb.SnapshotFile) error {
+	overwritten, err := repo.OverwrittenPaths(root, from, to)
+	if err != nil {
+		ui.Println(ui.Error("Failed to inspect workspace"))
+		return err
+	}
+	if len(overwritten) == 0 {
+		return nil
+	}
+
+	ui.Println(ui.Error("Local changes would be overwritten:"))
+	for _, path := range overwritten {
+		ui.Println(ui.Cross(path))
+	}
+	return errors.New("local changes would be overwritten")
+}
+
+// ensureConflictsSafe refuses to write conflict markers over paths with
+// local changes; ensureWorkspaceSafe cannot see them because conflicted
+// paths keep our hash in the merged tree.
+func ensureConflictsSafe(root string, ours []coredb.SnapshotFile, conflicts []ops.MergeConflict) error {
+	dirty, err := ops.DirtyConflicts(root, ours, conflicts)
+	if err != nil {
+		ui.Println(ui.Error("Failed to inspect workspace"))
+		return err
+	}
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	ui.Println(ui.Error("Local changes would be overwritten by conflicts:"))
+	for _, path := range dirty {
+		ui.Println(ui.Cross(path))
+	}
+	return errors.New("local changes would be overwritten")
+}
+
+func readMergeState(tx coredb.DBTX) (string, string, error) {
+	mergeHead, err := coredb.GetConfig(tx, mergeHeadKey)
+	if err == coreerrors.ErrDataNotFound {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	message, err := coredb.GetConfig(tx, mergeMessageKey)
+	if err != nil && err != coreerrors.ErrDataNotFound {
+		return "", "", err
+	}
+
+	return mergeHead, message, nil
+}
+
+func clearMergeState(tx coredb.DBTX) error {
+	if err := coredb.DeleteConfig(tx, mergeHeadKey); err != nil {
+		return err
+	}
+	return coredb.DeleteConfig(tx, mergeMessageKey)
+}
+
+func authorName() (string, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		ui.Println(ui.Error("Failed to load config"))
+		return "", err
+	}
+	if cfg.Name == "" {
+		ui.Println(ui.Error("No user name found\nadd one via kuro config --name <name>"))
+		return "", fmt.Errorf("no name found")
+	}
+	return cfg.Name, nil
+}
+
+func init() {
+	mergeCommand.Flags().Bool("abort", false, "abort the merge in progress")
+	mergeCommand.Flags().StringP("message", "m", "", "merge commit message")
+	rootCommand.AddCommand(mergeCommand)
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var rebaseCommand = &cobra.Command{
+	Use:          "rebase <upstream>",
+	Short:        "Replay the current branch on top of another tip",
+	Long:         "Replay the snapshots of the current branch that upstream does not contain on top of upstream (or --onto), producing new snapshots and a linear history",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		continueFlag, _ := cmd.Flags().GetBool("continue")
+		skipFlag, _ := cmd.Flags().GetBool("skip")
+		abortFlag, _ := cmd.Flags().GetBool("abort")
+		ontoFlag, _ := cmd.Flags().GetString("onto")
+
+		modes := 0
+		for _, set := range []bool{continueFlag, skipFlag, abortFlag} {
+			if set {
+				modes++
+			}
+		}
+		if modes > 1 {
+			ui.Println(ui.Error("Use only one of --continue, --skip or --abort"))
+			return errors.New("conflicting flags")
+		}
+		if modes > 0 && (len(args) > 0 || ontoFlag != "") {
+			ui.Println(ui.Error("No revisions expected with --continue, --skip or --abort"))
+			return errors.New("unexpected revisions")
+		}
+		if modes == 0 && len(args) == 0 {
+			ui.Println(ui.Error("Upstream required"))
+			return errors.New("upstream required")
+		}
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		var (
+			conflicts []ops.MergeConflict
+			status    string
+		)
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			s, err := readSequencer(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read sequencer state"))
+				return err
+			}
+
+			if modes > 0 {
+				if s == nil || s.Op != "rebase" {
+					ui.Println(ui.Error("No rebase in progress"))
+					return errors.New("no rebase in progress")
+				}
+				if abortFlag {
+					if err := s.abort(root, tx); err != nil {
+						return err
+					}
+					status = "Rebase aborted"
+					return nil
+				}
+				conflicts, err = s.resume(root, tx, skipFlag)
+				status = "Rebase complete, the previous tip is kept as ORIG_HEAD"
+				return err
+			}
+
+			if s != nil {
+				return ensureNoSequencer(tx)
+			}
+
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if mergeHead != "" {
+				ui.Println(ui.Error("A merge is in progress\ncommit the result or run kuro merge --abort"))
+				return errors.New("merge in progress")
+			}
+
+			stageFiles, err := coredb.GetStageFiles(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to get staged files"))
+				return err
+			}
+			if len(stageFiles) > 0 {
+				ui.Println(ui.Error("Staged changes present, commit them before rebasing"))
+				return errors.New("staged changes present")
+			}
+
+			head, err := coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+
+			ref, err := coredb.GetRef(tx, head)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve HEAD"))
+				return err
+			}
+			if ref.SnapshotHash == nil {
+				ui.Println(ui.Error("No commits yet"))
+				return errors.New("no commits")
+			}
+			tip := *ref.SnapshotHash
+
+			upstream, err := resolveRebaseRev(tx, args[0])
+			if err != nil {
+				return err
+			}
+			onto := upstream
+			if ontoFlag != "" {
+				onto, err = resolveRebaseRev(tx, ontoFlag)
+				if err != nil {
+					return err
+				}
+			}
+
+			todo, err := ops.RebaseTodo(tx, tip, upstream)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshots to replay"))
+				return err
+			}
+
+			// The branch is up to date when the snapshots to replay already
+			// form a straight line from onto to the tip.
+			previous := onto
+			for _, hash := range todo {
+				snapshot, err := coredb.GetSnapshot(tx, hash)
+				if err != nil {
+					ui.Println(ui.Error("Failed to read snapshot"))
+					return err
+				}
+				if snapshot.ParentHash == nil || *snapshot.ParentHash != previous {
+					break
+				}
+				previous = hash
+			}
+			if previous == tip {
+				status = fmt.Sprintf("%s is up to date", head)
+				return nil
+			}
+
+			tipFiles, err := treeAt(tx, &tip)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+			ontoFiles, err := treeAt(tx, &onto)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			if err := ensureWorkspaceSafe(root, tipFiles, ontoFiles); err != nil {
+				return err
+			}
+
+			if err := coredb.SetConfig(tx, ops.OrigHeadKey, tip); err != nil {
+				ui.Println(ui.Error("Failed to record ORIG_HEAD"))
+				return err
+			}
+
+			if err := coredb.UpdateRef(tx, head, &onto, "rebase: checkout "+args[0], reflogAuthor()); err != nil {
+				ui.Println(ui.Error("Failed to update ref"))
+				return err
+			}
+
+			if err := repo.ApplyTree(root, tx, tipFiles, ontoFiles); err != nil {
+				ui.Println(ui.Error("Failed to update workspace"))
+				return err
+			}
+
+			s = &sequencer{Op: "rebase", OrigHead: tip, Todo: todo}
+			conflicts, err = s.run(root, tx)
+			status = fmt.Sprintf("Rebased %s onto %s, the previous tip is kept as ORIG_HEAD", head, onto)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(conflicts) > 0 {
+			printSequencerConflicts("rebase", conflicts)
+			return errSequencerConflict
+		}
+
+		ui.Println(ui.Success(status))
+		return nil
+	},
+}
+
+func resolveRebaseRev(tx coredb.DBTX, rev string) (string, error) {
+	hash, err := ops.ResolveRev(tx, rev)
+	if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+		ui.Println(ui.Error("Revision not found: " + rev))
+		return "", err
+	}
+	if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+		ui.Println(ui.Error("Commit prefix is ambiguous: " + rev))
+		return "", err
+	}
+	if err != nil {
+		ui.Println(ui.Error("Failed to resolve revision"))
+		return "", err
+	}
+	return hash, nil
+}
+
+func init() {
+	rebaseCommand.Flags().String("onto", "", "replay onto this revision instead of upstream")
+	rebaseCommand.Flags().Bool("continue", false, "commit the resolved snapshot and replay the rest")
+	rebaseCommand.Flags().Bool("skip", false, "drop the conflicted snapshot and replay the rest")
+	rebaseCommand.Flags().Bool("abort", false, "restore the branch and workspace to before the rebase")
+	rootCommand.AddCommand(rebaseCommand)
+}
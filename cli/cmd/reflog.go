@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+
+	"github.com/spf13/cobra"
+)
+
+var reflogCommand = &cobra.Command{
+	Use:          "reflog [ref]",
+	Short:        "Show the history of ref movements",
+	Long:         "Show every recorded movement of a ref (or of all refs), newest first, so previous tips can be recovered",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		ref := ""
+		if len(args) == 1 {
+			ref = args[0]
+		}
+
+		entries, err := coredb.ListRefLog(db, ref)
+		if err != nil {
+			ui.Println(ui.Error("Failed to read ref log"))
+			return err
+		}
+
+		if len(entries) == 0 {
+			ui.Println(ui.Simple("No ref movements recorded"))
+			return nil
+		}
+
+		for _, entry := range entries {
+			// A ref also has no target when HEAD moves to an unborn branch.
+			target := "(none)"
+			switch {
+			case entry.NewHash != nil:
+				target = *entry.NewHash
+			case strings.HasSuffix(entry.Operation, ": deleted"):
+				target = "(deleted)"
+			}
+
+			line := fmt.Sprintf("%s  %s", target, entry.Operation)
+			if ref == "" {
+				line = fmt.Sprintf("%s  %s", entry.Ref, line)
+			}
+			ui.Println(ui.Step(line))
+
+			meta := time.Unix(entry.Timestamp, 0).Format("Mon Jan 2 15:04:05 2006")
+			if entry.Author != nil {
+				meta += "  " + *entry.Author
+			}
+			if entry.OldHash != nil {
+				meta += "  was " + *entry.OldHash
+			}
+			ui.Println(ui.Muted.Render("  " + meta))
+		}
+
+		return nil
+	},
+}
+
+// reflogAuthor returns the configured user name for ref log entries, or
+// nil when none is set; recording a movement never requires a name.
+func reflogAuthor() *string {
+	cfg, err := config.LoadConfig()
+	if err != nil || cfg.Name == "" {
+		return nil
+	}
+	return &cfg.Name
+}
+
+func init() {
+	rootCommand.AddCommand(reflogCommand)
+}
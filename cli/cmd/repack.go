@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var repackCommand = &cobra.Command{
+	Use:          "repack",
+	Short:        "Compress stored objects",
+	Long:         "Compress objects that are still stored uncompressed, then compact the database",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		ui.Println(ui.Step("Compressing objects..."))
+
+		var report *ops.RepackReport
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			report, err = ops.Repack(tx)
+			return err
+		})
+		if err != nil {
+			ui.Println(ui.Error("Failed to repack objects"))
+			return err
+		}
+
+		if err := coredb.Vacuum(db); err != nil {
+			ui.Println(ui.Error("Failed to vacuum database"))
+			return err
+		}
+
+		ui.Println(ui.Success(fmt.Sprintf(
+			"Compressed %d object(s), %s -> %s",
+			report.Objects, formatBytes(report.BytesBefore), formatBytes(report.BytesAfter),
+		)))
+		return nil
+	},
+}
+
+func init() {
+	rootCommand.AddCommand(repackCommand)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var resetCommand = &cobra.Command{
+	Use:   "reset [--soft|--mixed|--hard] <rev>",
+	Short: "Move the current branch to another snapshot",
+	Long: `Move the current branch to another snapshot.
+  --soft   keep the workspace and stage every path that differs from the old tip
+  --mixed  keep the workspace and clear the stage (default)
+  --hard   clear the stage and reset the workspace to the target snapshot
+A merge, cherry-pick, revert or rebase stopped on a conflict is dropped.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev := args[0]
+
+		soft, _ := cmd.Flags().GetBool("soft")
+		mixed, _ := cmd.Flags().GetBool("mixed")
+		hard, _ := cmd.Flags().GetBool("hard")
+
+		mode := ops.ResetMixed
+		switch {
+		case soft && !mixed && !hard:
+			mode = ops.ResetSoft
+		case hard && !soft && !mixed:
+			mode = ops.ResetHard
+		case soft || hard:
+			ui.Println(ui.Error("Choose only one of --soft, --mixed or --hard"))
+			return errors.New("conflicting reset modes")
+		}
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		var head, target string
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			head, err = coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+
+			target, err = ops.ResolveRev(tx, rev)
+			if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+				ui.Println(ui.Error("Revision not found"))
+				return err
+			}
+			if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+				ui.Println(ui.Error("Commit prefix is ambiguous"))
+				return err
+			}
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve revision"))
+				return err
+			}
+
+			operation := fmt.Sprintf("reset (%s): moving to %s", mode, rev)
+			if err := ops.Reset(tx, head, target, mode, operation, reflogAuthor()); err != nil {
+				ui.Println(ui.Error("Failed to reset " + head))
+				return err
+			}
+
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if mergeHead != "" {
+				if err := clearMergeState(tx); err != nil {
+					ui.Println(ui.Error("Failed to clear merge state"))
+					return err
+				}
+			}
+
+			// The branch has moved under a stopped run, so it can no
+			// longer be continued or aborted.
+			s, err := readSequencer(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read sequencer state"))
+				return err
+			}
+			if s != nil {
+				if err := clearSequencer(tx); err != nil {
+					ui.Println(ui.Error("Failed to clear " + s.Op + " state"))
+					return err
+				}
+			}
+
+			if mode == ops.ResetHard {
+				if err := repo.ResetWorkspace(root, tx, target); err != nil {
+					ui.Println(ui.Error("Failed to reset workspace"))
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ui.Println(ui.Success(fmt.Sprintf("%s is now at %s", head, target)))
+		return nil
+	},
+}
+
+func init() {
+	resetCommand.Flags().Bool("soft", false, "keep the workspace and stage the differences")
+	resetCommand.Flags().Bool("mixed", false, "keep the workspace and clear the stage (default)")
+	resetCommand.Flags().Bool("hard", false, "also reset the workspace to the target snapshot")
+	rootCommand.AddCommand(resetCommand)
+}
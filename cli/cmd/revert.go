@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var errRevertConflict = errors.New("revert conflict")
+
+var revertCommand = &cobra.Command{
+	Use:          "revert <rev>",
+	Short:        "Undo a snapshot with a new inverse snapshot",
+	Long:         "Apply the inverse of a snapshot (against its first parent) to HEAD and commit the result, without rewriting history",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev := args[0]
+		message, _ := cmd.Flags().GetString("message")
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		var (
+			conflicts []ops.MergeConflict
+			status    string
+		)
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			if err := ensureNoSequencer(tx); err != nil {
+				return err
+			}
+
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if mergeHead != "" {
+				ui.Println(ui.Error("A merge is in progress\ncommit the result or run kuro merge --abort"))
+				return errors.New("merge in progress")
+			}
+
+			stageFiles, err := coredb.GetStageFiles(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to get staged files"))
+				return err
+			}
+			if len(stageFiles) > 0 {
+				ui.Println(ui.Error("Staged changes present, commit them before reverting"))
+				return errors.New("staged changes present")
+			}
+
+			head, err := coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+
+			ref, err := coredb.GetRef(tx, head)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve HEAD"))
+				return err
+			}
+			if ref.SnapshotHash == nil {
+				ui.Println(ui.Error("No commits yet"))
+				return errors.New("no commits")
+			}
+
+			target, err := ops.ResolveRev(tx, rev)
+			if errors.Is(err, coreerrors.ErrRevisionNotFound) {
+				ui.Println(ui.Error("Revision not found"))
+				return err
+			}
+			if errors.Is(err, coreerrors.ErrAmbiguousRevision) {
+				ui.Println(ui.Error("Commit prefix is ambiguous"))
+				return err
+			}
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve revision"))
+				return err
+			}
+
+			snapshot, err := coredb.GetSnapshot(tx, target)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read snapshot"))
+				return err
+			}
+
+			headFiles, err := coredb.ListSnapshotFiles(tx, *ref.SnapshotHash)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			result, err := ops.RevertTree(tx, headFiles, target)
+			if err != nil {
+				ui.Println(ui.Error("Failed to compute revert"))
+				return err
+			}
+			if len(result.Conflicts) > 0 {
+				conflicts = result.Conflicts
+				return errRevertConflict
+			}
+
+			if coredb.CompareSnapshotFiles(headFiles, result.Files) {
+				status = "Nothing to revert, the changes are already undone"
+				return nil
+			}
+
+			if err := ensureWorkspaceSafe(root, headFiles, result.Files); err != nil {
+				return err
+			}
+
+			if strings.TrimSpace(message) == "" {
+				message = ops.RevertMessage(snapshot)
+			}
+
+			user, err := authorName()
+			if err != nil {
+				return err
+			}
+
+			snapshotHash, err := ops.CommitTree(tx, []string{*ref.SnapshotHash}, message, &user, result.Files)
+			if err != nil {
+				ui.Println(ui.Error("Failed to create snapshot"))
+				return err
+			}
+
+			if err := coredb.UpdateRef(tx, head, &snapshotHash, "revert: "+firstLine(message), &user); err != nil {
+				ui.Println(ui.Error("Failed to update ref"))
+				return err
+			}
+
+			if err := repo.ApplyTree(root, tx, headFiles, result.Files); err != nil {
+				ui.Println(ui.Error("Failed to update workspace"))
+				return err
+			}
+
+			status = fmt.Sprintf("Reverted %s as %s", target, snapshotHash)
+			return nil
+		})
+		if errors.Is(err, errRevertConflict) {
+			ui.Println(ui.Error("Revert conflicts with later changes, nothing was changed:"))
+			for _, c := range conflicts {
+				ui.Println(ui.Cross(c.Path))
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		ui.Println(ui.Success(status))
+		return nil
+	},
+}
+
+func init() {
+	revertCommand.Flags().StringP("message", "m", "", "revert commit message")
+	rootCommand.AddCommand(revertCommand)
+}
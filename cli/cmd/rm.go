@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var rmCommand = &cobra.Command{
+	Use:   "rm <path>",
+	Short: "Remove tracked files and stage their deletion",
+	Long: `Stage the deletion of the tracked files under a path and delete them from
+the workspace. --cached keeps the workspace files, which become untracked.
+Files whose workspace or staged content differs from HEAD are only removed
+with --force.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cached, _ := cmd.Flags().GetBool("cached")
+		force, _ := cmd.Flags().GetBool("force")
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open database"))
+			return err
+		}
+		defer db.Close()
+
+		path, err := resolveDiffPath(root, args[0])
+		if err != nil {
+			ui.Println(ui.Error("Invalid path"))
+			return err
+		}
+
+		headFiles, staged, err := ops.HeadAndStage(db)
+		if err != nil {
+			ui.Println(ui.Error("Failed to read stage"))
+			return err
+		}
+
+		var targets []string
+		for _, p := range trackedPaths(headFiles, staged) {
+			if p == path || strings.HasPrefix(p, path+"/") {
+				targets = append(targets, p)
+			}
+		}
+		if len(targets) == 0 {
+			ui.Println(ui.Error(fmt.Sprintf("Path is not tracked: %s", args[0])))
+			return fmt.Errorf("path not tracked")
+		}
+
+		inHead := make(map[string]string, len(headFiles))
+		for _, f := range headFiles {
+			inHead[f.Path] = f.ObjectHash
+		}
+
+		if !cached && !force {
+			cache, err := ops.LoadStatCache(db, root)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read stat cache"))
+				return err
+			}
+			defer saveStatCache(db, cache)
+
+			// Deleting these would lose content that no snapshot holds.
+			var changed []string
+			for _, p := range targets {
+				head, tracked := inHead[p]
+				if s, ok := staged[p]; ok && s.ObjectHash != "" && (!tracked || s.ObjectHash != head) {
+					changed = append(changed, p)
+					continue
+				}
+				hash, err := cache.Hash(p)
+				if os.IsNotExist(err) {
+					continue
+				}
+				if err != nil {
+					ui.Println(ui.Error("Failed to read " + p))
+					return err
+				}
+				if !tracked || hash != head {
+					changed = append(changed, p)
+				}
+			}
+			if len(changed) > 0 {
+				ui.Println(ui.Error("These files have changes not in HEAD:"))
+				for _, p := range changed {
+					ui.Println(ui.Simple("  " + p))
+				}
+				ui.Println(ui.Step("Use --cached to keep them in the workspace or --force to delete them"))
+				return fmt.Errorf("files have local changes")
+			}
+		}
+
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			for _, p := range targets {
+				// A path HEAD never had simply leaves the stage.
+				if _, ok := inHead[p]; !ok {
+					if err := coredb.RemoveStageFile(tx, p); err != nil {
+						return err
+					}
+				} else if err := coredb.StageDeletion(tx, p); err != nil {
+					return err
+				}
+
+				if cached {
+					continue
+				}
+				if err := repo.RemoveFile(root, p); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			ui.Println(ui.Error("Failed to remove files"))
+			return err
+		}
+
+		for _, p := range targets {
+			ui.Println(ui.Success("Removed " + p))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rmCommand.Flags().Bool("cached", false, "only stage the deletion, keep the workspace files")
+	rmCommand.Flags().BoolP("force", "f", false, "remove files even if they have changes not in HEAD")
+	rootCommand.AddCommand(rmCommand)
+}
@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+)
+
+const (
+	sequencerOpKey       = "sequencer_op"
+	sequencerOrigHeadKey = "sequencer_orig_head"
+	sequencerTodoKey     = "sequencer_todo"
+	sequencerCurrentKey  = "sequencer_current"
+	sequencerConflictKey = "sequencer_conflicts"
+)
+
+var errSequencerConflict = errors.New("conflict")
+
+// sequencer replays snapshots onto the current branch one at a time. Its
+// state lives in the config table so a run stopped by a conflict can be
+// continued or aborted later.
+type sequencer struct {
+	// Op names the command driving the run, e.g. "cherry-pick".
+	Op string
+	// OrigHead is the branch tip before the run, restored on abort. It is
+	// empty for an unborn branch.
+	OrigHead string
+	// Todo holds the snapshots still to apply, in order.
+	Todo []string
+	// Current is the snapshot whose conflicts are being resolved.
+	Current string
+	// Conflicts lists the paths of Current that must be staged before
+	// the run can continue.
+	Conflicts []string
+}
+
+// readSequencer returns the run in progress, or nil if there is none.
+func readSequencer(tx coredb.DBTX) (*sequencer, error) {
+	op, err := coredb.GetConfig(tx, sequencerOpKey)
+	if err == coreerrors.ErrDataNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sequencer{Op: op}
+	for key, dst := range map[string]*string{
+		sequencerOrigHeadKey: &s.OrigHead,
+		sequencerCurrentKey:  &s.Current,
+	} {
+		value, err := coredb.GetConfig(tx, key)
+		if err != nil && err != coreerrors.ErrDataNotFound {
+			return nil, err
+		}
+		*dst = value
+	}
+
+	for key, dst := range map[string]*[]string{
+		sequencerTodoKey:     &s.Todo,
+		sequencerConflictKey: &s.Conflicts,
+	} {
+		value, err := coredb.GetConfig(tx, key)
+		if err != nil && err != coreerrors.ErrDataNotFound {
+			return nil, err
+		}
+		if value != "" {
+			*dst = strings.Split(value, "\n")
+		}
+	}
+
+	return s, nil
+}
+
+func (s *sequencer) save(tx coredb.DBTX) error {
+	values := map[string]string{
+		sequencerOpKey:       s.Op,
+		sequencerOrigHeadKey: s.OrigHead,
+		sequencerTodoKey:     strings.Join(s.Todo, "\n"),
+		sequencerCurrentKey:  s.Current,
+		sequencerConflictKey: strings.Join(s.Conflicts, "\n"),
+	}
+	for key, value := range values {
+		if err := coredb.SetConfig(tx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clearSequencer(tx coredb.DBTX) error {
+	for _, key := range []string{sequencerOpKey, sequencerOrigHeadKey, sequencerTodoKey, sequencerCurrentKey, sequencerConflictKey} {
+		if err := coredb.DeleteConfig(tx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureNoSequencer refuses to start another operation while a run is
+// stopped on a conflict.
+func ensureNoSequencer(tx coredb.DBTX) error {
+	s, err := readSequencer(tx)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read sequencer state"))
+		return err
+	}
+	if s == nil {
+		return nil
+	}
+
+	ui.Println(ui.Error(fmt.Sprintf("A %s is in progress\nresolve conflicts, add the files and run kuro %s --continue, or run kuro %s --abort", s.Op, s.Op, s.Op)))
+	return fmt.Errorf("%s in progress", s.Op)
+}
+
+// run applies the remaining snapshots. It returns the conflicts of the
+// snapshot it stopped at, with the state saved, or nil once every
+// snapshot is applied and the state is cleared.
+func (s *sequencer) run(root string, tx coredb.DBTX) ([]ops.MergeConflict, error) {
+	head, err := coredb.GetConfig(tx, "head")
+	if err != nil {
+		ui.Println(ui.Error("Failed to read HEAD"))
+		return nil, err
+	}
+
+	for len(s.Todo) > 0 {
+		target := s.Todo[0]
+		s.Todo = s.Todo[1:]
+
+		ref, err := coredb.GetRef(tx, head)
+		if err != nil {
+			ui.Println(ui.Error("Failed to resolve HEAD"))
+			return nil, err
+		}
+
+		headFiles, err := treeAt(tx, ref.SnapshotHash)
+		if err != nil {
+			ui.Println(ui.Error("Failed to list snapshot files"))
+			return nil, err
+		}
+
+		result, err := ops.CherryPickTree(tx, headFiles, target)
+		if err != nil {
+			ui.Println(ui.Error("Failed to apply " + target))
+			return nil, err
+		}
+
+		if err := ensureWorkspaceSafe(root, headFiles, result.Files); err != nil {
+			return nil, err
+		}
+		if err := ensureConflictsSafe(root, headFiles, result.Conflicts); err != nil {
+			return nil, err
+		}
+
+		if len(result.Conflicts) > 0 {
+			if err := s.stop(root, tx, headFiles, result, target); err != nil {
+				return nil, err
+			}
+			return result.Conflicts, nil
+		}
+
+		if coredb.CompareSnapshotFiles(headFiles, result.Files) {
+			ui.Println(ui.Simple(fmt.Sprintf("Skipping %s, its changes are already applied", target)))
+			continue
+		}
+
+		snapshotHash, err := s.commit(tx, head, ref.SnapshotHash, target, result.Files)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := repo.ApplyTree(root, tx, headFiles, result.Files); err != nil {
+			ui.Println(ui.Error("Failed to update workspace"))
+			return nil, err
+		}
+
+		ui.Println(ui.Step(fmt.Sprintf("Applied %s as %s", target, snapshotHash)))
+	}
+
+	if err := clearSequencer(tx); err != nil {
+		ui.Println(ui.Error("Failed to clear sequencer state"))
+		return nil, err
+	}
+	return nil, nil
+}
+
+// stop writes a conflicted pick into the workspace, stages the cleanly
+// applied paths and saves the state for --continue.
+func (s *sequencer) stop(root string, tx coredb.DBTX, headFiles []coredb.SnapshotFile, result *ops.MergeResult, target string) error {
+	if err := repo.ApplyTree(root, tx, headFiles, result.Files); err != nil {
+		ui.Println(ui.Error("Failed to update workspace"))
+		return err
+	}
+
+	conflicted := make(map[string]struct{}, len(result.Conflicts))
+	s.Conflicts = s.Conflicts[:0]
+	for _, c := range result.Conflicts {
+		conflicted[c.Path] = struct{}{}
+		s.Conflicts = append(s.Conflicts, c.Path)
+		if c.Content == nil {
+			continue
+		}
+		if err := repo.WriteFile(root, c.Path, c.Content); err != nil {
+			ui.Println(ui.Error("Failed to write conflicted file"))
+			return err
+		}
+	}
+
+	headMap := make(map[string]string, len(headFiles))
+	for _, f := range headFiles {
+		headMap[f.Path] = f.ObjectHash
+	}
+	resultMap := make(map[string]string, len(result.Files))
+	for _, f := range result.Files {
+		resultMap[f.Path] = f.ObjectHash
+	}
+
+	var changed []string
+	for path, hash := range resultMap {
+		if headMap[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range headMap {
+		if _, ok := resultMap[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	for _, path := range changed {
+		if _, ok := conflicted[path]; ok {
+			continue
+		}
+		var err error
+		if hash, ok := resultMap[path]; ok {
+			err = coredb.AddStageFile(tx, path, hash)
+		} else {
+			err = coredb.StageDeletion(tx, path)
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to stage file"))
+			return err
+		}
+	}
+
+	s.Current = target
+	if err := s.save(tx); err != nil {
+		ui.Println(ui.Error("Failed to save sequencer state"))
+		return err
+	}
+	return nil
+}
+
+// resume carries on with the remaining snapshots after a conflict. It
+// commits the resolved pick from the staged files, refusing while a
+// conflicted path is not staged, or drops the pick when skip is set.
+func (s *sequencer) resume(root string, tx coredb.DBTX, skip bool) ([]ops.MergeConflict, error) {
+	if s.Current != "" {
+		head, err := coredb.GetConfig(tx, "head")
+		if err != nil {
+			ui.Println(ui.Error("Failed to read HEAD"))
+			return nil, err
+		}
+
+		ref, err := coredb.GetRef(tx, head)
+		if err != nil {
+			ui.Println(ui.Error("Failed to resolve HEAD"))
+			return nil, err
+		}
+
+		headFiles, err := treeAt(tx, ref.SnapshotHash)
+		if err != nil {
+			ui.Println(ui.Error("Failed to list snapshot files"))
+			return nil, err
+		}
+
+		if skip {
+			if err := s.restore(root, tx, headFiles); err != nil {
+				return nil, err
+			}
+			ui.Println(ui.Simple(fmt.Sprintf("Skipped %s", s.Current)))
+		} else {
+			unresolved, err := ops.UnresolvedConflicts(tx, s.Conflicts)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read staged files"))
+				return nil, err
+			}
+			if len(unresolved) > 0 {
+				ui.Println(ui.Error(fmt.Sprintf("Unresolved conflicts, fix them and add the files, or run kuro %s --skip", s.Op)))
+				for _, path := range unresolved {
+					ui.Println(ui.Cross(path))
+				}
+				return nil, errors.New("unresolved conflicts")
+			}
+
+			files, err := stagedTree(root, tx, ref.SnapshotHash, headFiles)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read staged files"))
+				return nil, err
+			}
+
+			if coredb.CompareSnapshotFiles(headFiles, files) {
+				ui.Println(ui.Error(fmt.Sprintf("Nothing left to commit for %s, run kuro %s --skip to drop it", s.Current, s.Op)))
+				return nil, errors.New("nothing to commit")
+			}
+
+			snapshotHash, err := s.commit(tx, head, ref.SnapshotHash, s.Current, files)
+			if err != nil {
+				return nil, err
+			}
+			ui.Println(ui.Step(fmt.Sprintf("Applied %s as %s", s.Current, snapshotHash)))
+		}
+
+		if err := coredb.ClearStage(tx); err != nil {
+			ui.Println(ui.Error("Failed to clear stage"))
+			return nil, err
+		}
+		s.Current = ""
+		s.Conflicts = nil
+	}
+
+	return s.run(root, tx)
+}
+
+// restore puts the workspace back to files, dropping the staged and
+// conflicted content of the stopped pick.
+func (s *sequencer) restore(root string, tx coredb.DBTX, files []coredb.SnapshotFile) error {
+	staged, err := stagedPaths(tx)
+	if err != nil {
+		ui.Println(ui.Error("Failed to get staged files"))
+		return err
+	}
+
+	workspace, err := ops.WorkspaceTree(tx, root, files, append(staged, s.Conflicts...))
+	if err != nil {
+		ui.Println(ui.Error("Failed to read workspace"))
+		return err
+	}
+
+	if err := repo.ApplyTree(root, tx, workspace, files); err != nil {
+		ui.Println(ui.Error("Failed to restore workspace"))
+		return err
+	}
+	return nil
+}
+
+// abort restores the branch and workspace to where the run started.
+func (s *sequencer) abort(root string, tx coredb.DBTX) error {
+	head, err := coredb.GetConfig(tx, "head")
+	if err != nil {
+		ui.Println(ui.Error("Failed to read HEAD"))
+		return err
+	}
+
+	ref, err := coredb.GetRef(tx, head)
+	if err != nil {
+		ui.Println(ui.Error("Failed to resolve HEAD"))
+		return err
+	}
+
+	headFiles, err := treeAt(tx, ref.SnapshotHash)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list snapshot files"))
+		return err
+	}
+
+	staged, err := stagedPaths(tx)
+	if err != nil {
+		ui.Println(ui.Error("Failed to get staged files"))
+		return err
+	}
+
+	workspace, err := ops.WorkspaceTree(tx, root, headFiles, append(staged, s.Conflicts...))
+	if err != nil {
+		ui.Println(ui.Error("Failed to read workspace"))
+		return err
+	}
+
+	var orig *string
+	if s.OrigHead != "" {
+		orig = &s.OrigHead
+	}
+
+	origFiles, err := treeAt(tx, orig)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list snapshot files"))
+		return err
+	}
+
+	if err := coredb.UpdateRef(tx, head, orig, s.Op+": abort", reflogAuthor()); err != nil {
+		ui.Println(ui.Error("Failed to restore " + head))
+		return err
+	}
+
+	if err := repo.ApplyTree(root, tx, workspace, origFiles); err != nil {
+		ui.Println(ui.Error("Failed to restore workspace"))
+		return err
+	}
+
+	if err := coredb.ClearStage(tx); err != nil {
+		ui.Println(ui.Error("Failed to clear stage"))
+		return err
+	}
+
+	if err := clearSequencer(tx); err != nil {
+		ui.Println(ui.Error("Failed to clear sequencer state"))
+		return err
+	}
+	return nil
+}
+
+// commit records files as the replayed version of target, keeping the
+// original author.
+func (s *sequencer) commit(tx coredb.DBTX, head string, tip *string, target string, files []coredb.SnapshotFile) (string, error) {
+	snapshot, err := coredb.GetSnapshot(tx, target)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read snapshot"))
+		return "", err
+	}
+
+	message := snapshot.Message
+	if s.Op == "cherry-pick" {
+		message = ops.CherryPickMessage(snapshot)
+	}
+
+	author := snapshot.Author
+	if author == nil {
+		author = reflogAuthor()
+	}
+
+	var parents []string
+	if tip != nil {
+		parents = append(parents, *tip)
+	}
+
+	snapshotHash, err := ops.CommitTree(tx, parents, message, author, files)
+	if err != nil {
+		ui.Println(ui.Error("Failed to create snapshot"))
+		return "", err
+	}
+
+	if err := coredb.UpdateRef(tx, head, &snapshotHash, s.Op+": "+firstLine(snapshot.Message), reflogAuthor()); err != nil {
+		ui.Println(ui.Error("Failed to update ref"))
+		return "", err
+	}
+
+	return snapshotHash, nil
+}
+
+// stagedTree applies the stage to the tip tree, the same way commit
+// builds a snapshot.
+func stagedTree(root string, tx coredb.DBTX, tip *string, headFiles []coredb.SnapshotFile) ([]coredb.SnapshotFile, error) {
+	stageFiles, err := coredb.GetStageFiles(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := stagedChanges(tx, root, headFiles, stageFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return ops.BuildTree(tx, tip, changes)
+}
+
+func printSequencerConflicts(op string, conflicts []ops.MergeConflict) {
+	ui.Println(ui.Warn(fmt.Sprintf("Conflicts stopped the %s, fix them, add the files and run kuro %s --continue, or kuro %s --skip to drop the snapshot", op, op, op)))
+	for _, c := range conflicts {
+		ui.Println(ui.Cross(c.Path))
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var showCommand = &cobra.Command{
+	Use:          "show [rev | rev:path]",
+	Short:        "Show a snapshot and its changes, or a file at a revision",
+	Long:         "Print a snapshot's metadata followed by its changes against its first parent, or with rev:path print a file's content at that revision",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stat, _ := cmd.Flags().GetBool("stat")
+		nameStatus, _ := cmd.Flags().GetBool("name-status")
+
+		if stat && nameStatus {
+			ui.Println(ui.Error("Use only one of --stat or --name-status"))
+			return errors.New("conflicting flags")
+		}
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		rev := "HEAD"
+		if len(args) == 1 {
+			rev = args[0]
+		}
+
+		rev, path, hasPath := strings.Cut(rev, ":")
+		if rev == "" {
+			rev = "HEAD"
+		}
+
+		target, err := resolveLogRev(db, rev)
+		if err != nil {
+			return err
+		}
+
+		if hasPath {
+			file, err := coredb.GetSnapshotFile(db, target, strings.TrimPrefix(path, "/"))
+			if err == coreerrors.ErrDataNotFound {
+				ui.Println(ui.Error(fmt.Sprintf("%s does not exist in %s", path, rev)))
+				return err
+			}
+			if err != nil {
+				ui.Println(ui.Error("Failed to read snapshot file"))
+				return err
+			}
+			if err := coredb.WriteObject(db, file.ObjectHash, os.Stdout); err != nil {
+				ui.Println(ui.Error("Failed to read object"))
+				return err
+			}
+			return nil
+		}
+
+		snapshot, err := coredb.GetSnapshot(db, target)
+		if err != nil {
+			ui.Println(ui.Error("Failed to read snapshot"))
+			return err
+		}
+
+		parents, err := coredb.ListSnapshotParents(db, target)
+		if err != nil {
+			ui.Println(ui.Error("Failed to read parents"))
+			return err
+		}
+
+		ui.Println(ui.KV("Snapshot", snapshot.Hash))
+		for _, parent := range parents {
+			ui.Println(ui.KV("Parent", parent))
+		}
+		ui.Println(ui.KV("Author", blameAuthor(snapshot)))
+		ui.Println(ui.KV("Date", time.Unix(snapshot.Timestamp, 0).Format("Mon Jan 2 15:04:05 2006")))
+		fmt.Println()
+		for _, line := range strings.Split(strings.TrimRight(snapshot.Message, "\n"), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+		fmt.Println()
+
+		files, firstParentFiles, err := snapshotTrees(db, target, parents)
+		if err != nil {
+			ui.Println(ui.Error("Failed to list snapshot files"))
+			return err
+		}
+
+		source := ops.ObjectSource(db)
+		changes, err := ops.DetectRenames(ops.DiffTrees(firstParentFiles, files), firstParentFiles, source, source, renameOptions(cmd))
+		if err != nil {
+			ui.Println(ui.Error("Failed to detect renames"))
+			return err
+		}
+
+		switch {
+		case nameStatus:
+			printNameStatus(changes)
+		case stat:
+			return printDiffStat(db, changes)
+		default:
+			if err := ops.WriteDiff(os.Stdout, changes, source, source); err != nil {
+				ui.Println(ui.Error("Failed to render diff"))
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+// snapshotTrees returns the files of a snapshot and of its first parent.
+func snapshotTrees(db coredb.DBTX, hash string, parents []string) ([]coredb.SnapshotFile, []coredb.SnapshotFile, error) {
+	files, err := coredb.ListSnapshotFiles(db, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parents) == 0 {
+		return files, nil, nil
+	}
+	parentFiles, err := coredb.ListSnapshotFiles(db, parents[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, parentFiles, nil
+}
+
+func printDiffStat(db coredb.DBTX, changes []ops.FileChange) error {
+	width := 0
+	for _, c := range changes {
+		if n := len(changeLabel(c)); n > width {
+			width = n
+		}
+	}
+
+	source := ops.ObjectSource(db)
+	insertions, deletions := 0, 0
+	for _, c := range changes {
+		oldContent, newContent, err := ops.LoadChange(c, source, source)
+		if errors.Is(err, coreerrors.ErrBinaryFile) {
+			fmt.Printf(" %-*s | Bin\n", width, changeLabel(c))
+			continue
+		}
+		if err != nil {
+			ui.Println(ui.Error(fmt.Sprintf("Failed to diff %s", c.Path)))
+			return err
+		}
+
+		added, removed := ops.LineStats(oldContent, newContent)
+		insertions += added
+		deletions += removed
+		fmt.Printf(" %-*s | %d %s\n", width, changeLabel(c), added+removed, statBar(added, removed))
+	}
+
+	fmt.Printf(" %d files changed, %d insertions(+), %d deletions(-)\n", len(changes), insertions, deletions)
+	return nil
+}
+
+// statBar draws added and removed line counts as + and -, scaled down to
+// at most statBarWidth characters.
+func statBar(added, removed int) string {
+	const statBarWidth = 40
+	if total := added + removed; total > statBarWidth {
+		added = added * statBarWidth / total
+		removed = statBarWidth - added
+	}
+	return strings.Repeat("+", added) + strings.Repeat("-", removed)
+}
+
+func init() {
+	showCommand.Flags().Bool("stat", false, "show a per-file summary of changed lines")
+	showCommand.Flags().Bool("name-status", false, "show only the status and path of changed files")
+	addRenameFlags(showCommand, true)
+	rootCommand.AddCommand(showCommand)
+}
@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/repo"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	coredb "github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var errStashConflict = errors.New("stash conflict")
+
+var stashCommand = &cobra.Command{
+	Use:   "stash",
+	Short: "Stash workspace changes",
+	Long:  "Save modified and staged files outside of any branch, restore the workspace to HEAD, and re-apply them later",
+}
+
+var pushStashCommand = &cobra.Command{
+	Use:          "push",
+	Short:        "Save workspace changes and restore the workspace to HEAD",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		message, _ := cmd.Flags().GetString("message")
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		status := ""
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			if err := ensureNoSequencer(tx); err != nil {
+				return err
+			}
+
+			mergeHead, _, err := readMergeState(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read merge state"))
+				return err
+			}
+			if mergeHead != "" {
+				ui.Println(ui.Error("Cannot stash during a merge\ncommit the result or run kuro merge --abort"))
+				return errors.New("merge in progress")
+			}
+
+			head, err := coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+
+			ref, err := coredb.GetRef(tx, head)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve HEAD"))
+				return err
+			}
+
+			headFiles, err := treeAt(tx, ref.SnapshotHash)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			staged, err := coredb.GetStageFiles(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to get staged files"))
+				return err
+			}
+			paths := make([]string, 0, len(staged))
+			for _, f := range staged {
+				paths = append(paths, f.Path)
+			}
+
+			tree, err := ops.WorkspaceTree(tx, root, headFiles, paths)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read workspace"))
+				return err
+			}
+
+			if len(staged) == 0 && coredb.CompareSnapshotFiles(headFiles, tree) {
+				status = "No local changes to save"
+				return nil
+			}
+
+			if strings.TrimSpace(message) == "" {
+				message = "WIP on " + head
+				if ref.SnapshotHash != nil {
+					snapshot, err := coredb.GetSnapshot(tx, *ref.SnapshotHash)
+					if err != nil {
+						ui.Println(ui.Error("Failed to read snapshot"))
+						return err
+					}
+					message += ": " + firstLine(snapshot.Message)
+				}
+			}
+
+			var parents []string
+			if ref.SnapshotHash != nil {
+				parents = append(parents, *ref.SnapshotHash)
+			}
+
+			snapshotHash, err := ops.CommitTree(tx, parents, "stash: "+message, reflogAuthor(), tree)
+			if err != nil {
+				ui.Println(ui.Error("Failed to create stash snapshot"))
+				return err
+			}
+
+			if _, err := coredb.CreateStash(tx, snapshotHash, ref.SnapshotHash, head, message, staged); err != nil {
+				ui.Println(ui.Error("Failed to record stash"))
+				return err
+			}
+
+			if err := repo.ApplyTree(root, tx, tree, headFiles); err != nil {
+				ui.Println(ui.Error("Failed to restore workspace"))
+				return err
+			}
+
+			if err := coredb.ClearStage(tx); err != nil {
+				ui.Println(ui.Error("Failed to clear stage"))
+				return err
+			}
+
+			status = "Saved workspace: " + message
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ui.Println(ui.Success(status))
+		return nil
+	},
+}
+
+var popStashCommand = &cobra.Command{
+	Use:          "pop [stash]",
+	Short:        "Re-apply a stash and drop it",
+	Long:         "Three-way apply a stash (stash@{0} by default) onto the workspace; on conflicts the stash is kept",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		var (
+			conflicts []ops.MergeConflict
+			name      string
+		)
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			stash, index, err := findStash(tx, args)
+			if err != nil {
+				return err
+			}
+			name = fmt.Sprintf("stash@{%d}", index)
+
+			head, err := coredb.GetConfig(tx, "head")
+			if err != nil {
+				ui.Println(ui.Error("Failed to read HEAD"))
+				return err
+			}
+
+			ref, err := coredb.GetRef(tx, head)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve HEAD"))
+				return err
+			}
+
+			headFiles, err := treeAt(tx, ref.SnapshotHash)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			staged, err := stagedPaths(tx)
+			if err != nil {
+				ui.Println(ui.Error("Failed to get staged files"))
+				return err
+			}
+
+			oursFiles, err := ops.WorkspaceTree(tx, root, headFiles, staged)
+			if err != nil {
+				ui.Println(ui.Error("Failed to read workspace"))
+				return err
+			}
+
+			baseFiles, err := treeAt(tx, stash.BaseHash)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			stashFiles, err := coredb.ListSnapshotFiles(tx, stash.SnapshotHash)
+			if err != nil {
+				ui.Println(ui.Error("Failed to list snapshot files"))
+				return err
+			}
+
+			result, err := ops.MergeTrees(tx, baseFiles, oursFiles, stashFiles, "Updated upstream", "Stashed changes")
+			if err != nil {
+				ui.Println(ui.Error("Failed to merge stash"))
+				return err
+			}
+
+			if err := ensureWorkspaceSafe(root, oursFiles, result.Files); err != nil {
+				return err
+			}
+
+			if err := repo.ApplyTree(root, tx, oursFiles, result.Files); err != nil {
+				ui.Println(ui.Error("Failed to update workspace"))
+				return err
+			}
+
+			for _, c := range result.Conflicts {
+				if c.Content == nil {
+					continue
+				}
+				if err := repo.WriteFile(root, c.Path, c.Content); err != nil {
+					ui.Println(ui.Error("Failed to write conflicted file"))
+					return err
+				}
+			}
+
+			if err := ops.RestoreStashedStage(tx, stash.ID, stashFiles, result); err != nil {
+				ui.Println(ui.Error("Failed to restore staged files"))
+				return err
+			}
+
+			if len(result.Conflicts) > 0 {
+				conflicts = result.Conflicts
+				return nil
+			}
+
+			if err := coredb.DeleteStash(tx, stash.ID); err != nil {
+				ui.Println(ui.Error("Failed to drop stash"))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(conflicts) > 0 {
+			ui.Println(ui.Warn(fmt.Sprintf("Conflicts while applying %s, fix them then add the files; the stash was kept", name)))
+			for _, c := range conflicts {
+				ui.Println(ui.Cross(c.Path))
+			}
+			return errStashConflict
+		}
+
+		ui.Println(ui.Success(fmt.Sprintf("Applied and dropped %s", name)))
+		return nil
+	},
+}
+
+var listStashCommand = &cobra.Command{
+	Use:          "list",
+	Short:        "List stashes",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		stashes, err := coredb.ListStashes(db)
+		if err != nil {
+			ui.Println(ui.Error("Failed to list stashes"))
+			return err
+		}
+
+		if len(stashes) == 0 {
+			ui.Println(ui.Simple("No stashes"))
+			return nil
+		}
+
+		for i, stash := range stashes {
+			ui.Println(ui.Bullet(fmt.Sprintf("stash@{%d}  %s  (%s)", i, stash.Message, stash.Branch)))
+		}
+
+		return nil
+	},
+}
+
+var dropStashCommand = &cobra.Command{
+	Use:          "drop [stash]",
+	Short:        "Delete a stash",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		db, err := coredb.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer db.Close()
+
+		name := ""
+		err = coredb.WithTx(context.Background(), db, func(tx coredb.DBTX) error {
+			stash, index, err := findStash(tx, args)
+			if err != nil {
+				return err
+			}
+			name = fmt.Sprintf("stash@{%d}", index)
+
+			if err := coredb.DeleteStash(tx, stash.ID); err != nil {
+				ui.Println(ui.Error("Failed to drop stash"))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ui.Println(ui.Success("Dropped " + name))
+		return nil
+	},
+}
+
+// findStash resolves an optional "N" or "stash@{N}" argument to a stash,
+// defaulting to the newest one.
+func findStash(tx coredb.DBTX, args []string) (*coredb.Stash, int, error) {
+	index := 0
+	if len(args) == 1 {
+		spec := strings.TrimSuffix(strings.TrimPrefix(args[0], "stash@{"), "}")
+		n, err := strconv.Atoi(spec)
+		if err != nil || n < 0 {
+			ui.Println(ui.Error("Invalid stash " + args[0]))
+			return nil, 0, errors.New("invalid stash")
+		}
+		index = n
+	}
+
+	stashes, err := coredb.ListStashes(tx)
+	if err != nil {
+		ui.Println(ui.Error("Failed to list stashes"))
+		return nil, 0, err
+	}
+	if index >= len(stashes) {
+		if len(stashes) == 0 {
+			ui.Println(ui.Error("No stashes"))
+		} else {
+			ui.Println(ui.Error(fmt.Sprintf("stash@{%d} does not exist", index)))
+		}
+		return nil, 0, errors.New("stash not found")
+	}
+
+	return &stashes[index], index, nil
+}
+
+// treeAt lists the files of a snapshot, or none for an unborn branch.
+func treeAt(tx coredb.DBTX, snapshotHash *string) ([]coredb.SnapshotFile, error) {
+	if snapshotHash == nil {
+		return []coredb.SnapshotFile{}, nil
+	}
+	return coredb.ListSnapshotFiles(tx, *snapshotHash)
+}
+
+func stagedPaths(tx coredb.DBTX) ([]string, error) {
+	stageFiles, err := coredb.GetStageFiles(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(stageFiles))
+	for _, f := range stageFiles {
+		paths = append(paths, f.Path)
+	}
+	return paths, nil
+}
+
+func init() {
+	pushStashCommand.Flags().StringP("message", "m", "", "describe the stash")
+
+	rootCommand.AddCommand(stashCommand)
+	stashCommand.AddCommand(pushStashCommand)
+	stashCommand.AddCommand(popStashCommand)
+	stashCommand.AddCommand(listStashCommand)
+	stashCommand.AddCommand(dropStashCommand)
+}
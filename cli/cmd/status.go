@@ -1,8 +1,8 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
-	"sort"
 
 	"github.com/greedypanda0/kuro/cli/internal/config"
 	"github.com/greedypanda0/kuro/cli/internal/ui"
@@ -15,12 +15,19 @@ import (
 )
 
 var statusCommand = &cobra.Command{
-	Use:           "status",
-	Short:         "Show the status",
-	Long:          "Show the status of various things",
-	SilenceUsage:  true,
+	Use:   "status",
+	Short: "Show the status",
+	Long: `Show the branch and the state of every changed file: staged changes
+against HEAD, unstaged changes against the stage, and untracked files.
+
+--short and --porcelain print one "XY path" line per file, where X is the
+staged state and Y the unstaged one (A added, M modified, D deleted,
+R renamed, ?? untracked); renames are printed as "XY old -> new". The
+--porcelain format is stable and never coloured.`,
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		stageFlag, _ := cmd.Flags().GetBool("stage")
+		short, _ := cmd.Flags().GetBool("short")
+		porcelain, _ := cmd.Flags().GetBool("porcelain")
 
 		root, err := config.RepoRoot()
 		if err != nil {
@@ -35,6 +42,22 @@ var statusCommand = &cobra.Command{
 		}
 		defer db.Close()
 
+		statuses, err := workingStatus(db, root, renameOptions(cmd))
+		if err != nil {
+			return err
+		}
+
+		if short || porcelain {
+			for _, s := range statuses {
+				code := s.Code()
+				if !porcelain {
+					code = statusCode(s)
+				}
+				fmt.Printf("%s %s\n", code, statusLabel(s))
+			}
+			return nil
+		}
+
 		head, err := coredb.GetConfig(db, "head")
 		if err != nil {
 			ui.Println(ui.Error("Failed to get HEAD"))
@@ -55,61 +78,43 @@ var statusCommand = &cobra.Command{
 			ui.Println(ui.Step(fmt.Sprintf("Commit: %s", *ref.SnapshotHash)))
 		}
 
-		if stageFlag {
-			stageFiles, err := coredb.GetStageFiles(db)
-			if err != nil {
-				ui.Println(ui.Error("Failed to get staged files"))
-				return err
+		var staged, unstaged, untracked []ops.FileStatus
+		for _, s := range statuses {
+			switch {
+			case s.Unstaged == ops.StatusUntracked:
+				untracked = append(untracked, s)
+				continue
+			case s.Staged != 0:
+				staged = append(staged, s)
 			}
-
-			ui.Println(ui.Header("Staged files"))
-
-			stagedSet := make(map[string]struct{}, len(stageFiles))
-			if len(stageFiles) == 0 {
-				ui.Println(ui.Simple("No files staged"))
-			} else {
-				for _, file := range stageFiles {
-					stagedSet[file.Path] = struct{}{}
-					ui.Println(ui.Bullet(file.Path))
-				}
-				ui.Println(ui.Step(fmt.Sprintf("Total: %d", len(stageFiles))))
+			if s.Unstaged != 0 {
+				unstaged = append(unstaged, s)
 			}
+		}
 
-			kuroIgnore, err := ops.ReadKuroIgnore(config.IgnorePathFor(root))
-			if err == coreerrors.ErrIgnoreFileNotFound {
-				kuroIgnore = []string{}
-			} else if err != nil {
-				ui.Println(ui.Error("Failed to read ignore file"))
-				return err
-			}
+		if len(statuses) == 0 {
+			ui.Println(ui.Simple("Nothing to commit, workspace clean"))
+			return nil
+		}
 
-			files, err := ops.ReadDir(root)
-			if err != nil {
-				ui.Println(ui.Error("Failed to read workspace"))
-				return err
+		if len(staged) > 0 {
+			ui.Println(ui.Header("Changes to be committed"))
+			for _, s := range staged {
+				ui.Println(ui.Simple(fmt.Sprintf("  %-10s %s", statusNames[s.Staged]+":", statusLabel(s))))
 			}
+		}
 
-			var unstaged []string
-			for _, file := range files {
-				if ops.IsIgnored(file.Path, kuroIgnore) {
-					continue
-				}
-				if _, ok := stagedSet[file.Path]; ok {
-					continue
-				}
-				unstaged = append(unstaged, file.Path)
+		if len(unstaged) > 0 {
+			ui.Println(ui.Header("Changes not staged"))
+			for _, s := range unstaged {
+				ui.Println(ui.Simple(fmt.Sprintf("  %-10s %s", statusNames[s.Unstaged]+":", statusLabel(s))))
 			}
+		}
 
-			sort.Strings(unstaged)
-
-			ui.Println(ui.Header("Unstaged files"))
-
-			if len(unstaged) == 0 {
-				ui.Println(ui.Simple("No unstaged files"))
-			} else {
-				for _, file := range unstaged {
-					ui.Println(ui.Simple("- " + file))
-				}
+		if len(untracked) > 0 {
+			ui.Println(ui.Header("Untracked files"))
+			for _, s := range untracked {
+				ui.Println(ui.Simple("  " + s.Path))
 			}
 		}
 
@@ -117,7 +122,104 @@ var statusCommand = &cobra.Command{
 	},
 }
 
+var statusNames = map[ops.ChangeStatus]string{
+	ops.StatusAdded:    "new file",
+	ops.StatusModified: "modified",
+	ops.StatusDeleted:  "deleted",
+	ops.StatusRenamed:  "renamed",
+	ops.StatusCopied:   "copied",
+}
+
+// workingStatus compares HEAD, the stage and every tracked or
+// non-ignored workspace file, detecting renames on both sides.
+func workingStatus(db *sql.DB, root string, opts ops.RenameOptions) ([]ops.FileStatus, error) {
+	headFiles, staged, err := ops.HeadAndStage(db)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read stage"))
+		return nil, err
+	}
+
+	kuroIgnore, err := ops.ReadKuroIgnore(config.IgnorePathFor(root))
+	if err == coreerrors.ErrIgnoreFileNotFound {
+		kuroIgnore = []string{}
+	} else if err != nil {
+		ui.Println(ui.Error("Failed to read ignore file"))
+		return nil, err
+	}
+
+	files, err := ops.ReadDir(root)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read workspace"))
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files)+len(headFiles)+len(staged))
+	for _, file := range files {
+		if !ops.IsIgnored(file.Path, kuroIgnore) {
+			paths = append(paths, file.Path)
+		}
+	}
+	for _, f := range headFiles {
+		paths = append(paths, f.Path)
+	}
+	for path := range staged {
+		paths = append(paths, path)
+	}
+
+	cache, err := ops.LoadStatCache(db, root)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read stat cache"))
+		return nil, err
+	}
+	defer saveStatCache(db, cache)
+
+	stageFiles, err := ops.StageTree(cache, headFiles, staged)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read workspace"))
+		return nil, err
+	}
+	workspaceFiles, err := cache.HashWorkspace(paths)
+	if err != nil {
+		ui.Println(ui.Error("Failed to read workspace"))
+		return nil, err
+	}
+
+	objects := ops.ObjectSource(db)
+	workspace := ops.WorkspaceSource(root)
+	stage := ops.StageSource(objects, workspace, staged)
+
+	stagedChanges, err := ops.DetectRenames(ops.DiffTrees(headFiles, stageFiles), headFiles, objects, stage, opts)
+	if err != nil {
+		ui.Println(ui.Error("Failed to detect renames"))
+		return nil, err
+	}
+	// Renames are only paired on the staged side: a file moved in the
+	// workspace is untracked until it is added.
+	return ops.WorkingStatus(stagedChanges, ops.DiffTrees(stageFiles, workspaceFiles)), nil
+}
+
+func statusLabel(s ops.FileStatus) string {
+	if s.OldPath != "" {
+		return s.OldPath + " -> " + s.Path
+	}
+	return s.Path
+}
+
+// statusCode colours the staged side of an XY code green and the unstaged
+// side red.
+func statusCode(s ops.FileStatus) string {
+	code := s.Code()
+	if s.Unstaged == ops.StatusUntracked {
+		return ui.Unstaged(code)
+	}
+	return ui.Staged(code[:1]) + ui.Unstaged(code[1:])
+}
+
 func init() {
 	statusCommand.Flags().BoolP("stage", "s", false, "show stage")
+	statusCommand.Flags().MarkDeprecated("stage", "status now always lists staged, unstaged and untracked files")
+	statusCommand.Flags().Bool("short", false, "show one XY code and path per changed file")
+	statusCommand.Flags().Bool("porcelain", false, "like --short, in a stable uncoloured format for scripts")
+	addRenameFlags(statusCommand, false)
 	rootCommand.AddCommand(statusCommand)
 }
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/greedypanda0/kuro/cli/internal/config"
+	"github.com/greedypanda0/kuro/cli/internal/ui"
+
+	"github.com/greedypanda0/kuro/core/db"
+	coreerrors "github.com/greedypanda0/kuro/core/errors"
+	"github.com/greedypanda0/kuro/core/ops"
+
+	"github.com/spf13/cobra"
+)
+
+var tagCommand = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage tags",
+	Long:  "Create, list, show, and delete tags. Tags are immutable unless replaced with --force",
+}
+
+var createTagCommand = &cobra.Command{
+	Use:          "create <name> [rev]",
+	Short:        "Tag a snapshot (HEAD by default)",
+	Args:         cobra.RangeArgs(1, 2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		rev := "HEAD"
+		if len(args) == 2 {
+			rev = args[1]
+		}
+
+		message, _ := cmd.Flags().GetString("message")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !validTagName(name) {
+			ui.Println(ui.Error("Invalid tag name"))
+			return errors.New("invalid tag name")
+		}
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		database, err := db.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer database.Close()
+
+		var msg, tagger *string
+		if strings.TrimSpace(message) != "" {
+			author, err := authorName()
+			if err != nil {
+				return err
+			}
+			msg, tagger = &message, &author
+		}
+
+		var snapshotHash string
+		err = db.WithTx(context.Background(), database, func(tx db.DBTX) error {
+			snapshotHash, err = ops.ResolveRev(tx, rev)
+			if err != nil {
+				ui.Println(ui.Error("Failed to resolve " + rev))
+				return err
+			}
+
+			_, err = db.GetTag(tx, name)
+			if err == nil {
+				if !force {
+					ui.Println(ui.Error("Tag already exists, use --force to replace it"))
+					return errors.New("tag already exists")
+				}
+				if err := db.DeleteTag(tx, name); err != nil {
+					ui.Println(ui.Error("Failed to replace tag"))
+					return err
+				}
+			} else if err != coreerrors.ErrTagNotFound {
+				ui.Println(ui.Error("Failed to check tag"))
+				return err
+			}
+
+			if err := db.CreateTag(tx, name, snapshotHash, msg, tagger); err != nil {
+				ui.Println(ui.Error("Failed to create tag"))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ui.Println(ui.Success(fmt.Sprintf("Tagged %s as %s", snapshotHash, name)))
+		return nil
+	},
+}
+
+var listTagCommand = &cobra.Command{
+	Use:          "list",
+	Short:        "List tags",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		database, err := db.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer database.Close()
+
+		tags, err := db.ListTags(database)
+		if err != nil {
+			ui.Println(ui.Error("Failed to list tags"))
+			return err
+		}
+
+		if len(tags) == 0 {
+			ui.Println(ui.Simple("No tags"))
+			return nil
+		}
+
+		for _, tag := range tags {
+			line := fmt.Sprintf("%s  %s", tag.Name, tag.SnapshotHash)
+			if tag.Message != nil {
+				line += "  " + firstLine(*tag.Message)
+			}
+			ui.Println(ui.Bullet(line))
+		}
+
+		return nil
+	},
+}
+
+var showTagCommand = &cobra.Command{
+	Use:          "show <name>",
+	Short:        "Show a tag and the snapshot it points to",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		database, err := db.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer database.Close()
+
+		tag, err := db.GetTag(database, args[0])
+		if err == coreerrors.ErrTagNotFound {
+			ui.Println(ui.Error("Tag does not exist"))
+			return err
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to read tag"))
+			return err
+		}
+
+		ui.Println(ui.Header("Tag " + tag.Name))
+		ui.Println(ui.KV("Snapshot", tag.SnapshotHash))
+		if tag.Tagger != nil {
+			ui.Println(ui.KV("Tagger", *tag.Tagger))
+		}
+		ui.Println(ui.KV("Date", time.Unix(tag.CreatedAt, 0).Format("Mon Jan 2 15:04:05 2006")))
+		if tag.Message != nil {
+			ui.Println(ui.KV("Message", *tag.Message))
+		}
+
+		snapshot, err := db.GetSnapshot(database, tag.SnapshotHash)
+		if err == coreerrors.ErrSnapshotNotFound {
+			ui.Println(ui.Warn("Tagged snapshot is missing, run kuro fsck for details"))
+			return nil
+		}
+		if err != nil {
+			ui.Println(ui.Error("Failed to read snapshot"))
+			return err
+		}
+
+		ui.Println(ui.Step(fmt.Sprintf("%s  %s", snapshot.Hash, snapshot.Message)))
+		return nil
+	},
+}
+
+var deleteTagCommand = &cobra.Command{
+	Use:          "delete <name>",
+	Short:        "Delete a tag",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		root, err := config.RepoRoot()
+		if err != nil {
+			ui.Println(ui.Error("Repository not initialized"))
+			return err
+		}
+
+		database, err := db.OpenDB(config.DatabasePathFor(root))
+		if err != nil {
+			ui.Println(ui.Error("Failed to open repository"))
+			return err
+		}
+		defer database.Close()
+
+		err = db.WithTx(context.Background(), database, func(tx db.DBTX) error {
+			err := db.DeleteTag(tx, name)
+			if err == coreerrors.ErrTagNotFound {
+				ui.Println(ui.Error("Tag does not exist"))
+				return err
+			}
+			if err != nil {
+				ui.Println(ui.Error("Failed to delete tag"))
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ui.Println(ui.Success("Deleted tag " + name))
+		return nil
+	},
+}
+
+// validTagName rejects names that would be ambiguous as revisions.
+func validTagName(name string) bool {
+	if name == "" || strings.ToLower(name) == "head" {
+		return false
+	}
+	return !strings.ContainsAny(name, "~^: \t\n")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func init() {
+	createTagCommand.Flags().StringP("message", "m", "", "annotate the tag with a message")
+	createTagCommand.Flags().BoolP("force", "f", false, "replace an existing tag")
+
+	rootCommand.AddCommand(tagCommand)
+	tagCommand.AddCommand(createTagCommand)
+	tagCommand.AddCommand(listTagCommand)
+	tagCommand.AddCommand(showTagCommand)
+	tagCommand.AddCommand(deleteTagCommand)
+}
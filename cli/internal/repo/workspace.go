@@ -42,16 +42,7 @@ func ResetWorkspace(root string, db coredb.DBTX, snapshotHash string) error {
 	}
 
 	for _, f := range snapshotFiles {
-		obj, err := coredb.GetObject(db, f.ObjectHash)
-		if err != nil {
-			return err
-		}
-
-		abs := filepath.Join(root, filepath.FromSlash(f.Path))
-		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
-			return err
-		}
-		if err := os.WriteFile(abs, obj.Content, 0o644); err != nil {
+		if err := writeObject(root, db, f.Path, f.ObjectHash); err != nil {
 			return err
 		}
 	}
@@ -101,3 +92,133 @@ func isKuroPath(relPath string) bool {
 	parts := strings.Split(filepath.Clean(relPath), string(os.PathSeparator))
 	return len(parts) > 0 && parts[0] == config.RepoDir
 }
+
+// ApplyTree moves the workspace from one tree to another, touching only
+// the paths whose object differs between them.
+func ApplyTree(root string, db coredb.DBTX, from, to []coredb.SnapshotFile) error {
+	fromMap := make(map[string]string, len(from))
+	for _, f := range from {
+		fromMap[f.Path] = f.ObjectHash
+	}
+	toMap := make(map[string]string, len(to))
+	for _, f := range to {
+		toMap[f.Path] = f.ObjectHash
+	}
+
+	for _, f := range from {
+		if _, ok := toMap[f.Path]; ok {
+			continue
+		}
+		if err := RemoveFile(root, f.Path); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range to {
+		if fromMap[f.Path] == f.ObjectHash {
+			continue
+		}
+		if err := writeObject(root, db, f.Path, f.ObjectHash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OverwrittenPaths lists the paths ApplyTree(from, to) would clobber
+// because their workspace content no longer matches from.
+func OverwrittenPaths(root string, from, to []coredb.SnapshotFile) ([]string, error) {
+	fromMap := make(map[string]string, len(from))
+	for _, f := range from {
+		fromMap[f.Path] = f.ObjectHash
+	}
+	toMap := make(map[string]string, len(to))
+	for _, f := range to {
+		toMap[f.Path] = f.ObjectHash
+	}
+
+	touched := map[string]struct{}{}
+	for path, hash := range toMap {
+		if fromMap[path] != hash {
+			touched[path] = struct{}{}
+		}
+	}
+	for path := range fromMap {
+		if _, ok := toMap[path]; !ok {
+			touched[path] = struct{}{}
+		}
+	}
+
+	var overwritten []string
+	for path := range touched {
+		hash, err := ops.HashFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				_, tracked := fromMap[path]
+				_, kept := toMap[path]
+				if tracked && kept {
+					overwritten = append(overwritten, path)
+				}
+				continue
+			}
+			return nil, err
+		}
+
+		if hash != fromMap[path] && hash != toMap[path] {
+			overwritten = append(overwritten, path)
+		}
+	}
+
+	sort.Strings(overwritten)
+	return overwritten, nil
+}
+
+// WriteFile writes content to a repository-relative path, creating
+// parent directories as needed.
+func WriteFile(root, relPath string, content []byte) error {
+	abs := filepath.Join(root, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(abs, content, 0o644)
+}
+
+// writeObject streams an object into a repository-relative path, so large
+// chunked files are never held in memory whole.
+func writeObject(root string, db coredb.DBTX, relPath, hash string) error {
+	abs := filepath.Join(root, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(abs)
+	if err != nil {
+		return err
+	}
+
+	if err := coredb.WriteObject(db, hash, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RemoveFile deletes a repository-relative path and any parent
+// directories left empty.
+func RemoveFile(root, relPath string) error {
+	abs := filepath.Join(root, filepath.FromSlash(relPath))
+	if err := os.Remove(abs); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for dir := filepath.Dir(abs); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			break
+		}
+		_ = os.Remove(dir)
+	}
+
+	return nil
+}
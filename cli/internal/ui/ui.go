@@ -148,6 +148,56 @@ func ArrowUp(text string) string {
 		Render("↑ " + text)
 }
 
+/*
+Commit graph
+*/
+var laneColors = []lipgloss.Color{ColorPrimary, ColorInfo, ColorSuccess, ColorWarn, ColorError}
+
+// Graph colours each lane of a commit graph prefix; lane i occupies
+// columns 2i and 2i+1.
+func Graph(prefix string) string {
+	var b strings.Builder
+	for i, r := range prefix {
+		if r == ' ' {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString(lipgloss.NewStyle().
+			Foreground(laneColors[(i/2)%len(laneColors)]).
+			Render(string(r)))
+	}
+	return b.String()
+}
+
+// RefLabel renders the branch and tag names pointing at a snapshot.
+func RefLabel(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return lipgloss.NewStyle().
+		Bold(true).
+		Foreground(ColorSuccess).
+		Render("(" + strings.Join(names, ", ") + ")")
+}
+
+/*
+File status codes
+*/
+
+// Staged renders a status code for a change recorded in the stage.
+func Staged(code string) string {
+	return lipgloss.NewStyle().
+		Foreground(ColorSuccess).
+		Render(code)
+}
+
+// Unstaged renders a status code for a workspace change not yet staged.
+func Unstaged(code string) string {
+	return lipgloss.NewStyle().
+		Foreground(ColorError).
+		Render(code)
+}
+
 /*
 Key-value row (nice for config / status)
 */
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// ChunkRef is one entry of a chunked object's manifest.
+type ChunkRef struct {
+	Hash string
+	Size int64
+}
+
+// CreateChunkedObject stores a manifest object whose content is the
+// concatenation of the given chunk objects. The chunks must already exist.
+func CreateChunkedObject(db DBTX, hash string, chunks []ChunkRef) error {
+	codec, stored, err := encodeContent(encodeManifest(chunks))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT OR IGNORE INTO objects (hash, content, codec, kind) VALUES (?, ?, ?, ?)",
+		hash,
+		stored,
+		codec,
+		KindChunks,
+	)
+	return err
+}
+
+// ListObjectChunks returns the manifest of a chunked object, or nil when
+// the object is stored in one piece.
+func ListObjectChunks(db DBTX, hash string) ([]ChunkRef, error) {
+	var (
+		stored []byte
+		codec  string
+		kind   string
+	)
+
+	err := db.QueryRow(
+		"SELECT content, codec, kind FROM objects WHERE hash = ?",
+		hash,
+	).Scan(&stored, &codec, &kind)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != KindChunks {
+		return nil, nil
+	}
+
+	manifest, err := decodeContent(codec, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeManifest(hash, manifest)
+}
+
+// WriteObject streams an object's content to w. Chunked objects are
+// written one chunk at a time instead of being assembled in memory.
+func WriteObject(db DBTX, hash string, w io.Writer) error {
+	chunks, err := ListObjectChunks(db, hash)
+	if err != nil {
+		return err
+	}
+
+	if chunks == nil {
+		obj, err := GetObject(db, hash)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(obj.Content)
+		return err
+	}
+
+	for _, chunk := range chunks {
+		obj, err := GetObject(db, chunk.Hash)
+		if err != nil {
+			return fmt.Errorf("chunk %s of %s: %w", chunk.Hash, hash, err)
+		}
+		if _, err := w.Write(obj.Content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeManifest(chunks []ChunkRef) []byte {
+	var buf bytes.Buffer
+	for _, chunk := range chunks {
+		fmt.Fprintf(&buf, "%s %d\n", chunk.Hash, chunk.Size)
+	}
+	return buf.Bytes()
+}
+
+func decodeManifest(hash string, manifest []byte) ([]ChunkRef, error) {
+	chunks := []ChunkRef{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: bad manifest line in %s", errors.ErrCorruptObject, hash)
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad chunk size in %s", errors.ErrCorruptObject, hash)
+		}
+		chunks = append(chunks, ChunkRef{Hash: fields[0], Size: size})
+	}
+
+	return chunks, scanner.Err()
+}
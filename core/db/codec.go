@@ -0,0 +1,55 @@
+package db
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// Object codecs describe how content is stored in objects.content.
+// Hashes are always computed over the decoded bytes.
+const (
+	CodecRaw     = "raw"
+	CodecDeflate = "deflate"
+)
+
+// encodeContent compresses content, falling back to raw storage when
+// compression does not save space.
+func encodeContent(content []byte) (string, []byte, error) {
+	if len(content) == 0 {
+		return CodecRaw, content, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return "", nil, err
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+
+	if buf.Len() >= len(content) {
+		return CodecRaw, content, nil
+	}
+	return CodecDeflate, buf.Bytes(), nil
+}
+
+func decodeContent(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case CodecRaw:
+		return data, nil
+	case CodecDeflate:
+		r := flate.NewReader(bytes.NewReader(data))
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", errors.ErrUnsupportedCodec, codec)
+	}
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// MaxDeltaDepth bounds how many deltas GetObject may apply to rebuild an
+// object; deeper versions are stored in full.
+const MaxDeltaDepth = 10
+
+const (
+	deltaBlockSize = 16
+
+	deltaOpCopy   byte = 0
+	deltaOpInsert byte = 1
+)
+
+// computeDelta encodes target as copy and insert instructions against
+// base. The header records both lengths so corrupt deltas are detected.
+func computeDelta(base, target []byte) []byte {
+	var out []byte
+	out = binary.AppendUvarint(out, uint64(len(base)))
+	out = binary.AppendUvarint(out, uint64(len(target)))
+
+	index := map[uint32]int{}
+	for i := 0; i+deltaBlockSize <= len(base); i += deltaBlockSize {
+		h := blockHash(base[i : i+deltaBlockSize])
+		if _, ok := index[h]; !ok {
+			index[h] = i
+		}
+	}
+
+	insert := func(data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		out = append(out, deltaOpInsert)
+		out = binary.AppendUvarint(out, uint64(len(data)))
+		out = append(out, data...)
+	}
+
+	pending := 0
+	for j := 0; j+deltaBlockSize <= len(target); {
+		off, ok := index[blockHash(target[j:j+deltaBlockSize])]
+		if !ok || !bytes.Equal(base[off:off+deltaBlockSize], target[j:j+deltaBlockSize]) {
+			j++
+			continue
+		}
+
+		start, baseStart := j, off
+		for start > pending && baseStart > 0 && target[start-1] == base[baseStart-1] {
+			start--
+			baseStart--
+		}
+		end, baseEnd := j+deltaBlockSize, off+deltaBlockSize
+		for end < len(target) && baseEnd < len(base) && target[end] == base[baseEnd] {
+			end++
+			baseEnd++
+		}
+
+		insert(target[pending:start])
+		out = append(out, deltaOpCopy)
+		out = binary.AppendUvarint(out, uint64(baseStart))
+		out = binary.AppendUvarint(out, uint64(end-start))
+
+		j, pending = end, end
+	}
+	insert(target[pending:])
+
+	return out
+}
+
+func applyDelta(base, delta []byte) ([]byte, error) {
+	corrupt := func(reason string) error {
+		return fmt.Errorf("%w: %s", errors.ErrCorruptDelta, reason)
+	}
+
+	baseLen, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, corrupt("bad header")
+	}
+	delta = delta[n:]
+	if baseLen != uint64(len(base)) {
+		return nil, corrupt("base length mismatch")
+	}
+
+	targetLen, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, corrupt("bad header")
+	}
+	delta = delta[n:]
+
+	out := make([]byte, 0, targetLen)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch op {
+		case deltaOpCopy:
+			off, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return nil, corrupt("bad copy offset")
+			}
+			delta = delta[n:]
+			length, n := binary.Uvarint(delta)
+			if n <= 0 {
+				return nil, corrupt("bad copy length")
+			}
+			delta = delta[n:]
+			if off+length > uint64(len(base)) {
+				return nil, corrupt("copy out of range")
+			}
+			out = append(out, base[off:off+length]...)
+		case deltaOpInsert:
+			length, n := binary.Uvarint(delta)
+			if n <= 0 || uint64(len(delta)-n) < length {
+				return nil, corrupt("bad insert")
+			}
+			delta = delta[n:]
+			out = append(out, delta[:length]...)
+			delta = delta[length:]
+		default:
+			return nil, corrupt("unknown instruction")
+		}
+	}
+
+	if uint64(len(out)) != targetLen {
+		return nil, corrupt("target length mismatch")
+	}
+
+	return out, nil
+}
+
+func blockHash(block []byte) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(block)
+	return h.Sum32()
+}
@@ -0,0 +1,59 @@
+package db
+
+// IndexEntry caches the object hash of a workspace file together with the
+// stat data it was computed from. MTime and IndexedAt are in nanoseconds.
+type IndexEntry struct {
+	Path       string
+	Size       int64
+	MTime      int64
+	Inode      uint64
+	ObjectHash string
+	IndexedAt  int64
+}
+
+func ListIndexEntries(db DBTX) (map[string]IndexEntry, error) {
+	rows, err := db.Query("SELECT path, size, mtime, inode, object_hash, indexed_at FROM file_index")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := map[string]IndexEntry{}
+	for rows.Next() {
+		var (
+			e     IndexEntry
+			inode int64
+		)
+		if err := rows.Scan(&e.Path, &e.Size, &e.MTime, &inode, &e.ObjectHash, &e.IndexedAt); err != nil {
+			return nil, err
+		}
+		e.Inode = uint64(inode)
+		entries[e.Path] = e
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func PutIndexEntry(db DBTX, e IndexEntry) error {
+	_, err := db.Exec(
+		`INSERT INTO file_index (path, size, mtime, inode, object_hash, indexed_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET size = excluded.size, mtime = excluded.mtime, inode = excluded.inode,
+			object_hash = excluded.object_hash, indexed_at = excluded.indexed_at`,
+		e.Path,
+		e.Size,
+		e.MTime,
+		int64(e.Inode),
+		e.ObjectHash,
+		e.IndexedAt,
+	)
+	return err
+}
+
+func DeleteIndexEntry(db DBTX, path string) error {
+	_, err := db.Exec("DELETE FROM file_index WHERE path = ?", path)
+	return err
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// HistoryQuery selects snapshots on the first-parent chain of Tip. Zero
+// values disable the corresponding filter.
+type HistoryQuery struct {
+	Tip string
+	// Exclude hides every snapshot reachable from it, for A..B ranges.
+	Exclude *string
+	// Since and Until bound the timestamp, inclusive, in Unix seconds.
+	Since int64
+	Until int64
+	// Author and Grep match case-insensitive substrings of the author and
+	// message.
+	Author string
+	Grep   string
+	// Path keeps snapshots that changed the file, or any file below the
+	// directory, compared to their first parent.
+	Path  string
+	Limit int
+}
+
+// QueryHistory returns the snapshots matching q, newest first, using a
+// single recursive query. It returns ErrSnapshotNotFound when the chain
+// reaches a snapshot that is missing from the database.
+func QueryHistory(db DBTX, q HistoryQuery) ([]Snapshot, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	prefix := strings.TrimSuffix(q.Path, "/") + "/"
+	like := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+
+	rows, err := db.Query(`
+		WITH RECURSIVE
+		chain(hash, depth) AS (
+			SELECT ?, 0
+			UNION ALL
+			SELECT s.parent_hash, c.depth + 1
+			FROM snapshot s
+			JOIN chain c ON s.hash = c.hash
+			WHERE s.parent_hash IS NOT NULL
+		),
+		excluded(hash) AS (
+			SELECT ? WHERE ? IS NOT NULL
+			UNION
+			SELECT sp.parent_hash
+			FROM snapshot_parents sp
+			JOIN excluded e ON sp.snapshot_hash = e.hash
+		)
+		SELECT c.hash, s.hash, s.parent_hash, s.message, s.author, s.timestamp
+		FROM chain c
+		LEFT JOIN snapshot s ON s.hash = c.hash
+		WHERE c.hash NOT IN (SELECT hash FROM excluded)
+			AND (s.hash IS NULL OR (
+				(? = 0 OR s.timestamp >= ?)
+				AND (? = 0 OR s.timestamp <= ?)
+				AND (? = '' OR instr(lower(coalesce(s.author, '')), lower(?)) > 0)
+				AND (? = '' OR instr(lower(s.message), lower(?)) > 0)
+				AND (? = '' OR EXISTS (
+					SELECT 1 FROM snapshot_files a
+					WHERE a.snapshot_hash = s.hash
+						AND (a.path = ? OR a.path LIKE ? ESCAPE '\')
+						AND NOT EXISTS (
+							SELECT 1 FROM snapshot_files b
+							WHERE b.snapshot_hash = s.parent_hash AND b.path = a.path AND b.object_hash = a.object_hash
+						)
+				) OR EXISTS (
+					SELECT 1 FROM snapshot_files b
+					WHERE b.snapshot_hash = s.parent_hash
+						AND (b.path = ? OR b.path LIKE ? ESCAPE '\')
+						AND NOT EXISTS (
+							SELECT 1 FROM snapshot_files a
+							WHERE a.snapshot_hash = s.hash AND a.path = b.path
+						)
+				))
+			))
+		ORDER BY c.depth
+		LIMIT ?`,
+		q.Tip,
+		q.Exclude, q.Exclude,
+		q.Since, q.Since,
+		q.Until, q.Until,
+		q.Author, q.Author,
+		q.Grep, q.Grep,
+		q.Path, q.Path, like, q.Path, like,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var (
+			chainHash string
+			hash      sql.NullString
+			parent    sql.NullString
+			message   sql.NullString
+			author    sql.NullString
+			timestamp sql.NullInt64
+		)
+
+		if err := rows.Scan(&chainHash, &hash, &parent, &message, &author, &timestamp); err != nil {
+			return nil, err
+		}
+		if !hash.Valid {
+			return nil, errors.ErrSnapshotNotFound
+		}
+
+		s := Snapshot{Hash: hash.String, Message: message.String, Timestamp: timestamp.Int64}
+		if parent.Valid {
+			s.ParentHash = &parent.String
+		}
+		if author.Valid {
+			s.Author = &author.String
+		}
+
+		snapshots = append(snapshots, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
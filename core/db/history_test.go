@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestQueryHistoryFilters(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	if err := ApplySchema(db); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+
+	alice, bob := "alice", "bob"
+	commits := []struct {
+		hash    string
+		parents []string
+		message string
+		author  *string
+		files   map[string]string
+	}{
+		{"s1", nil, "initial import", &alice, map[string]string{"a.txt": "a1", "dir/b.txt": "b1"}},
+		{"s2", []string{"s1"}, "fix typo", &bob, map[string]string{"a.txt": "a2", "dir/b.txt": "b1"}},
+		{"side", []string{"s1"}, "side work", &bob, map[string]string{"a.txt": "a1"}},
+		{"s3", []string{"s2", "side"}, "Merge side", &alice, map[string]string{"a.txt": "a2"}},
+		{"s4", []string{"s3"}, "Fix the build", &alice, map[string]string{"a.txt": "a2", "dir/c.txt": "c1"}},
+	}
+	for i, c := range commits {
+		if err := CreateSnapshot(db, c.hash, c.parents, c.message, c.author); err != nil {
+			t.Fatalf("create snapshot: %v", err)
+		}
+		if _, err := db.Exec("UPDATE snapshot SET timestamp = ? WHERE hash = ?", 1000+i*100, c.hash); err != nil {
+			t.Fatalf("set timestamp: %v", err)
+		}
+		for path, object := range c.files {
+			if err := CreateSnapshotFile(db, c.hash, path, object); err != nil {
+				t.Fatalf("create snapshot file: %v", err)
+			}
+		}
+	}
+
+	s2 := "s2"
+	cases := []struct {
+		name string
+		q    HistoryQuery
+		want []string
+	}{
+		{"all", HistoryQuery{Tip: "s4"}, []string{"s4", "s3", "s2", "s1"}},
+		{"limit", HistoryQuery{Tip: "s4", Limit: 2}, []string{"s4", "s3"}},
+		{"range", HistoryQuery{Tip: "s4", Exclude: &s2}, []string{"s4", "s3"}},
+		{"since until", HistoryQuery{Tip: "s4", Since: 1100, Until: 1300}, []string{"s3", "s2"}},
+		{"author", HistoryQuery{Tip: "s4", Author: "BOB"}, []string{"s2"}},
+		{"grep", HistoryQuery{Tip: "s4", Grep: "fix"}, []string{"s4", "s2"}},
+		{"file", HistoryQuery{Tip: "s4", Path: "a.txt"}, []string{"s2", "s1"}},
+		{"directory", HistoryQuery{Tip: "s4", Path: "dir"}, []string{"s4", "s3", "s1"}},
+	}
+	for _, c := range cases {
+		snapshots, err := QueryHistory(db, c.q)
+		if err != nil {
+			t.Fatalf("%s: query history: %v", c.name, err)
+		}
+		var got []string
+		for _, s := range snapshots {
+			got = append(got, s.Hash)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+			}
+		}
+	}
+}
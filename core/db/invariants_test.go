@@ -47,10 +47,10 @@ func TestStagingLifecycle(t *testing.T) {
 		t.Fatalf("apply schema: %v", err)
 	}
 
-	if err := AddStageFile(db, "file-a.txt"); err != nil {
+	if err := AddStageFile(db, "file-a.txt", ""); err != nil {
 		t.Fatalf("add stage file: %v", err)
 	}
-	if err := AddStageFile(db, "dir/file-b.txt"); err != nil {
+	if err := AddStageFile(db, "dir/file-b.txt", "b1"); err != nil {
 		t.Fatalf("add stage file: %v", err)
 	}
 
@@ -62,6 +62,19 @@ func TestStagingLifecycle(t *testing.T) {
 		t.Fatalf("expected 2 staged files, got %d", len(files))
 	}
 
+	if err := StageDeletion(db, "file-a.txt"); err != nil {
+		t.Fatalf("stage deletion: %v", err)
+	}
+	files, err = GetStageFiles(db)
+	if err != nil {
+		t.Fatalf("get stage files: %v", err)
+	}
+	for _, f := range files {
+		if f.Path == "file-a.txt" && (!f.Deleted || f.ObjectHash != "") {
+			t.Fatalf("expected a staged deletion, got %+v", f)
+		}
+	}
+
 	if err := RemoveStageFile(db, "file-a.txt"); err != nil {
 		t.Fatalf("remove stage file: %v", err)
 	}
@@ -70,7 +83,7 @@ func TestStagingLifecycle(t *testing.T) {
 	if err != nil {
 		t.Fatalf("get stage files: %v", err)
 	}
-	if len(files) != 1 || files[0].Path != "dir/file-b.txt" {
+	if len(files) != 1 || files[0].Path != "dir/file-b.txt" || files[0].ObjectHash != "b1" {
 		t.Fatalf("unexpected staged files after remove")
 	}
 
@@ -3,30 +3,70 @@ package db
 import (
 	"github.com/greedypanda0/kuro/core/errors"
 	"database/sql"
+	"fmt"
+)
+
+// Object kinds. A blob stores its content directly, a delta stores the
+// instructions to rebuild it from BaseHash, and a chunks object stores a
+// manifest of chunk objects to concatenate.
+const (
+	KindBlob   = "blob"
+	KindDelta  = "delta"
+	KindChunks = "chunks"
 )
 
 type Object struct {
 	Hash      string
 	Content   []byte
+	Codec     string
+	Kind      string
+	BaseHash  *string
+	Depth     int
 	CreatedAt int64
 }
 
+// ObjectInfo describes a stored object without loading its content.
+type ObjectInfo struct {
+	Hash       string
+	Codec      string
+	Kind       string
+	BaseHash   *string
+	Depth      int
+	StoredSize int64
+	CreatedAt  int64
+}
+
 func CreateObject(db DBTX, hash string, content []byte) error {
-	_, err := db.Exec(
-		"INSERT OR IGNORE INTO objects (hash, content) VALUES (?, ?)",
+	codec, stored, err := encodeContent(content)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT OR IGNORE INTO objects (hash, content, codec) VALUES (?, ?, ?)",
 		hash,
-		content,
+		stored,
+		codec,
 	)
 	return err
 }
 
+// GetObject returns the object with its full content, applying delta
+// chains as needed.
 func GetObject(db DBTX, hash string) (*Object, error) {
-	var obj Object
+	return getObject(db, hash, MaxDeltaDepth)
+}
+
+func getObject(db DBTX, hash string, budget int) (*Object, error) {
+	var (
+		obj    Object
+		stored []byte
+	)
 
 	err := db.QueryRow(
-		"SELECT hash, content, created_at FROM objects WHERE hash = ?",
+		"SELECT hash, content, codec, kind, base_hash, depth, created_at FROM objects WHERE hash = ?",
 		hash,
-	).Scan(&obj.Hash, &obj.Content, &obj.CreatedAt)
+	).Scan(&obj.Hash, &stored, &obj.Codec, &obj.Kind, &obj.BaseHash, &obj.Depth, &obj.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, errors.ErrObjectNotFound
@@ -35,9 +75,131 @@ func GetObject(db DBTX, hash string) (*Object, error) {
 		return nil, err
 	}
 
+	if err := resolveContent(db, &obj, stored, budget); err != nil {
+		return nil, err
+	}
+
 	return &obj, nil
 }
 
+func resolveContent(db DBTX, obj *Object, stored []byte, budget int) error {
+	payload, err := decodeContent(obj.Codec, stored)
+	if err != nil {
+		return err
+	}
+
+	switch obj.Kind {
+	case KindBlob:
+		obj.Content = payload
+		return nil
+	case KindDelta:
+		if obj.BaseHash == nil {
+			return fmt.Errorf("%w: %s has no base", errors.ErrCorruptDelta, obj.Hash)
+		}
+		if budget <= 0 {
+			return fmt.Errorf("%w: %s exceeds maximum chain depth", errors.ErrCorruptDelta, obj.Hash)
+		}
+
+		base, err := getObject(db, *obj.BaseHash, budget-1)
+		if err == errors.ErrObjectNotFound {
+			return fmt.Errorf("%w: %s is missing base %s", errors.ErrCorruptDelta, obj.Hash, *obj.BaseHash)
+		}
+		if err != nil {
+			return err
+		}
+
+		obj.Content, err = applyDelta(base.Content, payload)
+		return err
+	case KindChunks:
+		chunks, err := decodeManifest(obj.Hash, payload)
+		if err != nil {
+			return err
+		}
+
+		var content []byte
+		for _, chunk := range chunks {
+			part, err := getObject(db, chunk.Hash, MaxDeltaDepth)
+			if err != nil {
+				return fmt.Errorf("chunk %s of %s: %w", chunk.Hash, obj.Hash, err)
+			}
+			content = append(content, part.Content...)
+		}
+		obj.Content = content
+		return nil
+	default:
+		return fmt.Errorf("%w: %s has unknown kind %q", errors.ErrCorruptObject, obj.Hash, obj.Kind)
+	}
+}
+
+// CreateDeltaObject stores content as a delta against base when that
+// saves enough space and keeps the chain within MaxDeltaDepth. Otherwise
+// the content is stored in full.
+func CreateDeltaObject(db DBTX, hash string, content []byte, base string) error {
+	var exists int
+	err := db.QueryRow("SELECT 1 FROM objects WHERE hash = ?", hash).Scan(&exists)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if base == "" || base == hash {
+		return CreateObject(db, hash, content)
+	}
+
+	var (
+		baseKind  string
+		baseDepth int
+	)
+	err = db.QueryRow("SELECT kind, depth FROM objects WHERE hash = ?", base).Scan(&baseKind, &baseDepth)
+	if err == sql.ErrNoRows {
+		return CreateObject(db, hash, content)
+	}
+	if err != nil {
+		return err
+	}
+	if baseKind == KindChunks || baseDepth >= MaxDeltaDepth {
+		return CreateObject(db, hash, content)
+	}
+
+	baseObj, err := GetObject(db, base)
+	if err != nil {
+		return err
+	}
+
+	delta := computeDelta(baseObj.Content, content)
+	if len(delta) >= len(content)/2 {
+		return CreateObject(db, hash, content)
+	}
+
+	codec, stored, err := encodeContent(delta)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT OR IGNORE INTO objects (hash, content, codec, kind, base_hash, depth) VALUES (?, ?, ?, ?, ?, ?)",
+		hash,
+		stored,
+		codec,
+		KindDelta,
+		base,
+		baseObj.Depth+1,
+	)
+	return err
+}
+
+// HasObject reports whether an object is stored.
+func HasObject(db DBTX, hash string) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM objects WHERE hash = ?", hash).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 func DeleteObject(db DBTX, hash string) error {
 	res, err := db.Exec(
 		"DELETE FROM objects WHERE hash = ?",
@@ -59,24 +221,107 @@ func DeleteObject(db DBTX, hash string) error {
 }
 
 func ListObjects(db DBTX) ([]Object, error) {
-	rows, err := db.Query("SELECT hash, content, created_at FROM objects ORDER BY created_at")
+	rows, err := db.Query("SELECT hash, content, codec, kind, base_hash, depth, created_at FROM objects ORDER BY created_at")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var objects []Object
+	var (
+		objects []Object
+		stored  [][]byte
+	)
 	for rows.Next() {
-		var obj Object
-		if err := rows.Scan(&obj.Hash, &obj.Content, &obj.CreatedAt); err != nil {
+		var (
+			obj Object
+			raw []byte
+		)
+		if err := rows.Scan(&obj.Hash, &raw, &obj.Codec, &obj.Kind, &obj.BaseHash, &obj.Depth, &obj.CreatedAt); err != nil {
 			return nil, err
 		}
 		objects = append(objects, obj)
+		stored = append(stored, raw)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	// Deltas may query their bases, so content is resolved once the
+	// listing cursor is closed.
+	for i := range objects {
+		if err := resolveContent(db, &objects[i], stored[i], MaxDeltaDepth); err != nil {
+			return nil, err
+		}
+	}
 
 	return objects, nil
 }
+
+func ListObjectInfo(db DBTX) ([]ObjectInfo, error) {
+	rows, err := db.Query("SELECT hash, codec, kind, base_hash, depth, length(content), created_at FROM objects ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []ObjectInfo
+	for rows.Next() {
+		var info ObjectInfo
+		if err := rows.Scan(&info.Hash, &info.Codec, &info.Kind, &info.BaseHash, &info.Depth, &info.StoredSize, &info.CreatedAt); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// RecompressObject re-encodes a raw object in place and returns its stored
+// size before and after.
+func RecompressObject(db DBTX, hash string) (int64, int64, error) {
+	var (
+		stored []byte
+		codec  string
+	)
+
+	err := db.QueryRow(
+		"SELECT content, codec FROM objects WHERE hash = ?",
+		hash,
+	).Scan(&stored, &codec)
+	if err == sql.ErrNoRows {
+		return 0, 0, errors.ErrObjectNotFound
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	before := int64(len(stored))
+	if codec != CodecRaw {
+		return before, before, nil
+	}
+
+	newCodec, encoded, err := encodeContent(stored)
+	if err != nil {
+		return 0, 0, err
+	}
+	if newCodec == CodecRaw {
+		return before, before, nil
+	}
+
+	if _, err := db.Exec(
+		"UPDATE objects SET content = ?, codec = ? WHERE hash = ?",
+		encoded,
+		newCodec,
+		hash,
+	); err != nil {
+		return 0, 0, err
+	}
+
+	return before, int64(len(encoded)), nil
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+func TestObjectsAreCompressedTransparently(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	if err := ApplySchema(db); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("kuro keeps text small\n"), 200)
+	if err := CreateObject(db, "text", content); err != nil {
+		t.Fatalf("create object: %v", err)
+	}
+
+	infos, err := ListObjectInfo(db)
+	if err != nil {
+		t.Fatalf("list object info: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Codec != CodecDeflate || infos[0].StoredSize >= int64(len(content)) {
+		t.Fatalf("expected compressed storage, got %+v", infos)
+	}
+
+	obj, err := GetObject(db, "text")
+	if err != nil {
+		t.Fatalf("get object: %v", err)
+	}
+	if !bytes.Equal(obj.Content, content) {
+		t.Fatalf("content did not round-trip")
+	}
+}
+
+func TestLegacyRawObjectsCanBeRepacked(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	if err := ApplySchema(db); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+
+	content := bytes.Repeat([]byte("legacy "), 100)
+	if _, err := db.Exec("INSERT INTO objects (hash, content) VALUES (?, ?)", "legacy", content); err != nil {
+		t.Fatalf("insert legacy object: %v", err)
+	}
+
+	before, after, err := RecompressObject(db, "legacy")
+	if err != nil {
+		t.Fatalf("recompress: %v", err)
+	}
+	if after >= before {
+		t.Fatalf("expected repack to shrink object, %d -> %d", before, after)
+	}
+
+	obj, err := GetObject(db, "legacy")
+	if err != nil {
+		t.Fatalf("get object: %v", err)
+	}
+	if obj.Codec != CodecDeflate || !bytes.Equal(obj.Content, content) {
+		t.Fatalf("unexpected repacked object: codec=%s", obj.Codec)
+	}
+}
+
+func TestDeltaObjectsRebuildFromBase(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	if err := ApplySchema(db); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+
+	var lines []byte
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line %d of a slowly changing file\n", i)...)
+	}
+
+	versions := [][]byte{lines}
+	prev := ""
+	for i := 0; i <= MaxDeltaDepth+1; i++ {
+		content := versions[len(versions)-1]
+		if i > 0 {
+			content = append(bytes.Clone(content), fmt.Sprintf("edit %d\n", i)...)
+			versions = append(versions, content)
+		}
+		hash := fmt.Sprintf("v%d", i)
+		if err := CreateDeltaObject(db, hash, content, prev); err != nil {
+			t.Fatalf("create %s: %v", hash, err)
+		}
+		prev = hash
+	}
+
+	infos, err := ListObjectInfo(db)
+	if err != nil {
+		t.Fatalf("list object info: %v", err)
+	}
+	for i, info := range infos {
+		if info.Depth > MaxDeltaDepth {
+			t.Fatalf("%s exceeds max depth: %d", info.Hash, info.Depth)
+		}
+		if i == 1 && (info.Kind != KindDelta || info.BaseHash == nil || *info.BaseHash != "v0") {
+			t.Fatalf("expected v1 to be a delta against v0, got %+v", info)
+		}
+		if i == MaxDeltaDepth+1 && info.Kind != KindBlob {
+			t.Fatalf("expected chain to restart with a full object, got %+v", info)
+		}
+	}
+
+	for i, want := range versions {
+		obj, err := GetObject(db, fmt.Sprintf("v%d", i))
+		if err != nil {
+			t.Fatalf("get v%d: %v", i, err)
+		}
+		if !bytes.Equal(obj.Content, want) {
+			t.Fatalf("v%d did not round-trip", i)
+		}
+	}
+}
+
+func TestApplyDeltaRejectsWrongBase(t *testing.T) {
+	delta := computeDelta([]byte("the original base content here"), []byte("the original base content, edited"))
+	if _, err := applyDelta([]byte("something else"), delta); err == nil {
+		t.Fatalf("expected corrupt delta error")
+	}
+}
@@ -0,0 +1,77 @@
+package db
+
+import "database/sql"
+
+// RefLogEntry records one movement of a ref. A nil OldHash means the ref
+// was created or unborn, a nil NewHash that it was deleted.
+type RefLogEntry struct {
+	ID        int64
+	Ref       string
+	OldHash   *string
+	NewHash   *string
+	Operation string
+	Author    *string
+	Timestamp int64
+}
+
+func AppendRefLog(db DBTX, ref string, oldHash, newHash *string, operation string, author *string) error {
+	_, err := db.Exec(
+		"INSERT INTO ref_log (ref, old_hash, new_hash, operation, author) VALUES (?, ?, ?, ?, ?)",
+		ref,
+		oldHash,
+		newHash,
+		operation,
+		author,
+	)
+	return err
+}
+
+// ListRefLog returns the entries for ref, newest first. An empty ref
+// lists the entries of every ref.
+func ListRefLog(db DBTX, ref string) ([]RefLogEntry, error) {
+	query := "SELECT id, ref, old_hash, new_hash, operation, author, timestamp FROM ref_log"
+	var args []any
+	if ref != "" {
+		query += " WHERE ref = ?"
+		args = append(args, ref)
+	}
+	query += " ORDER BY id DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []RefLogEntry
+	for rows.Next() {
+		var (
+			entry   RefLogEntry
+			oldHash sql.NullString
+			newHash sql.NullString
+			author  sql.NullString
+		)
+
+		if err := rows.Scan(&entry.ID, &entry.Ref, &oldHash, &newHash, &entry.Operation, &author, &entry.Timestamp); err != nil {
+			return nil, err
+		}
+
+		if oldHash.Valid {
+			entry.OldHash = &oldHash.String
+		}
+		if newHash.Valid {
+			entry.NewHash = &newHash.String
+		}
+		if author.Valid {
+			entry.Author = &author.String
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
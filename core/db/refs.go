@@ -53,7 +53,13 @@ func SetRef(db DBTX, name string, snapshotHash *string) error {
 	return err
 }
 
-func UpdateRef(db DBTX, name string, snapshotHash *string) error {
+// UpdateRef moves a ref and records the movement in the ref log.
+func UpdateRef(db DBTX, name string, snapshotHash *string, operation string, author *string) error {
+	ref, err := GetRef(db, name)
+	if err != nil {
+		return err
+	}
+
 	res, err := db.Exec(
 		"UPDATE refs SET snapshot_hash = ?, updated_at = (strftime('%s', 'now')) WHERE name = ?",
 		snapshotHash,
@@ -71,7 +77,7 @@ func UpdateRef(db DBTX, name string, snapshotHash *string) error {
 		return errors.ErrRefNotFound
 	}
 
-	return nil
+	return AppendRefLog(db, name, ref.SnapshotHash, snapshotHash, operation, author)
 }
 
 func GetRef(db DBTX, name string) (*Ref, error) {
@@ -53,5 +53,94 @@ CREATE TABLE IF NOT EXISTS staged_files (
 -- Defaults
 INSERT OR IGNORE INTO refs (name, snapshot_hash) VALUES ('main', NULL);
 INSERT OR IGNORE INTO config (key, value) VALUES ('head', 'main');
+`,
+	`-- Snapshot parents (ordered; merges have two or more)
+CREATE TABLE IF NOT EXISTS snapshot_parents (
+	snapshot_hash TEXT NOT NULL,
+	parent_hash TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (snapshot_hash, position),
+	FOREIGN KEY(snapshot_hash) REFERENCES snapshot(hash) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS snapshot_parents_parent ON snapshot_parents(parent_hash);
+
+INSERT OR IGNORE INTO snapshot_parents (snapshot_hash, parent_hash, position)
+SELECT hash, parent_hash, 0 FROM snapshot WHERE parent_hash IS NOT NULL;
+`,
+	`-- Objects may be stored compressed; hashes cover the uncompressed content
+ALTER TABLE objects ADD COLUMN codec TEXT NOT NULL DEFAULT 'raw';
+`,
+	`-- Delta objects store a binary delta against base_hash; depth counts
+-- how many deltas must be applied to rebuild the content
+ALTER TABLE objects ADD COLUMN kind TEXT NOT NULL DEFAULT 'blob';
+ALTER TABLE objects ADD COLUMN base_hash TEXT;
+ALTER TABLE objects ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+`,
+	`-- Tags are immutable named pointers to snapshots; a message makes them
+-- annotated
+CREATE TABLE IF NOT EXISTS tags (
+	name TEXT PRIMARY KEY,
+	snapshot_hash TEXT NOT NULL,
+	message TEXT,
+	tagger TEXT,
+	created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+	FOREIGN KEY (snapshot_hash) REFERENCES snapshot(hash)
+);
+`,
+	`-- Every ref movement is recorded so previous tips can be recovered
+CREATE TABLE IF NOT EXISTS ref_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	ref TEXT NOT NULL,
+	old_hash TEXT,
+	new_hash TEXT,
+	operation TEXT NOT NULL,
+	author TEXT,
+	timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ref_log_ref ON ref_log(ref, id);
+`,
+	`-- Stashes keep a workspace snapshot (parented on HEAD) and the staged
+-- paths outside of any branch
+CREATE TABLE IF NOT EXISTS stash (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	snapshot_hash TEXT NOT NULL,
+	base_hash TEXT,
+	branch TEXT NOT NULL,
+	message TEXT NOT NULL,
+	created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+	FOREIGN KEY (snapshot_hash) REFERENCES snapshot(hash)
+);
+
+CREATE TABLE IF NOT EXISTS stash_staged_files (
+	stash_id INTEGER NOT NULL,
+	path TEXT NOT NULL,
+	PRIMARY KEY (stash_id, path),
+	FOREIGN KEY (stash_id) REFERENCES stash(id)
+);
+`,
+	`-- Stat cache: the object hash of each workspace file with the stat data
+-- it was computed from; times are in nanoseconds
+CREATE TABLE IF NOT EXISTS file_index (
+	path TEXT PRIMARY KEY CHECK (path != ''),
+	size INTEGER NOT NULL,
+	mtime INTEGER NOT NULL,
+	inode INTEGER NOT NULL,
+	object_hash TEXT NOT NULL,
+	indexed_at INTEGER NOT NULL
+);
+`,
+	`-- Staging records content: add stores the object and its hash here, so
+-- later edits are not committed. Rows without a hash predate this and
+-- take their content from the workspace.
+ALTER TABLE staged_files ADD COLUMN object_hash TEXT;
+`,
+	`-- Staged deletions: the path leaves the next snapshot
+ALTER TABLE staged_files ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0;
+`,
+	`-- Stashes keep the staged content and deletions, not only the paths
+ALTER TABLE stash_staged_files ADD COLUMN object_hash TEXT;
+ALTER TABLE stash_staged_files ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0;
 `,
 }
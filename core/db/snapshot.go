@@ -13,7 +13,14 @@ type Snapshot struct {
 	Timestamp  int64
 }
 
-func CreateSnapshot(db DBTX, hash string, parentHash *string, message string, author *string) error {
+// CreateSnapshot records a snapshot and its ordered parents. The first
+// parent is also kept in snapshot.parent_hash.
+func CreateSnapshot(db DBTX, hash string, parents []string, message string, author *string) error {
+	var parentHash *string
+	if len(parents) > 0 {
+		parentHash = &parents[0]
+	}
+
 	_, err := db.Exec(
 		"INSERT INTO snapshot (hash, parent_hash, message, author) VALUES (?, ?, ?, ?)",
 		hash,
@@ -21,7 +28,17 @@ func CreateSnapshot(db DBTX, hash string, parentHash *string, message string, au
 		message,
 		author,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	for i, parent := range parents {
+		if err := CreateSnapshotParent(db, hash, parent, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func GetSnapshot(db DBTX, hash string) (*Snapshot, error) {
@@ -108,3 +125,31 @@ func ListSnapshots(db DBTX) ([]Snapshot, error) {
 
 	return snapshots, nil
 }
+
+// FindSnapshotsByPrefix returns the hashes of snapshots starting with prefix.
+func FindSnapshotsByPrefix(db DBTX, prefix string) ([]string, error) {
+	rows, err := db.Query(
+		"SELECT hash FROM snapshot WHERE substr(hash, 1, ?) = ? ORDER BY hash",
+		len(prefix),
+		prefix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
@@ -60,6 +60,14 @@ func DeleteSnapshotFile(db DBTX, snapshotHash, filePath string) error {
 	return nil
 }
 
+func DeleteSnapshotFiles(db DBTX, snapshotHash string) error {
+	_, err := db.Exec(
+		"DELETE FROM snapshot_files WHERE snapshot_hash = ?",
+		snapshotHash,
+	)
+	return err
+}
+
 func ListSnapshotFiles(db DBTX, snapshotHash string) ([]SnapshotFile, error) {
 	rows, err := db.Query(
 		"SELECT snapshot_hash, path, object_hash FROM snapshot_files WHERE snapshot_hash = ? ORDER BY path",
@@ -86,6 +94,36 @@ func ListSnapshotFiles(db DBTX, snapshotHash string) ([]SnapshotFile, error) {
 	return files, nil
 }
 
+// ListOrphanSnapshotFiles returns file rows whose snapshot no longer exists.
+func ListOrphanSnapshotFiles(db DBTX) ([]SnapshotFile, error) {
+	rows, err := db.Query(`
+		SELECT f.snapshot_hash, f.path, f.object_hash
+		FROM snapshot_files f
+		LEFT JOIN snapshot s ON s.hash = f.snapshot_hash
+		WHERE s.hash IS NULL
+		ORDER BY f.snapshot_hash, f.path`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []SnapshotFile
+	for rows.Next() {
+		var sf SnapshotFile
+		if err := rows.Scan(&sf.SnapshotHash, &sf.Path, &sf.ObjectHash); err != nil {
+			return nil, err
+		}
+		files = append(files, sf)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
 func CompareSnapshotFiles(a, b []SnapshotFile) bool {
 	if len(a) != len(b) {
 		return false
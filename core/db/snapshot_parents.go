@@ -0,0 +1,111 @@
+package db
+
+type SnapshotParent struct {
+	SnapshotHash string
+	ParentHash   string
+	Position     int
+}
+
+func CreateSnapshotParent(db DBTX, snapshotHash, parentHash string, position int) error {
+	_, err := db.Exec(
+		"INSERT INTO snapshot_parents (snapshot_hash, parent_hash, position) VALUES (?, ?, ?)",
+		snapshotHash,
+		parentHash,
+		position,
+	)
+	return err
+}
+
+func DeleteSnapshotParents(db DBTX, snapshotHash string) error {
+	_, err := db.Exec(
+		"DELETE FROM snapshot_parents WHERE snapshot_hash = ?",
+		snapshotHash,
+	)
+	return err
+}
+
+func ListSnapshotParents(db DBTX, snapshotHash string) ([]string, error) {
+	rows, err := db.Query(
+		"SELECT parent_hash FROM snapshot_parents WHERE snapshot_hash = ? ORDER BY position",
+		snapshotHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parents []string
+	for rows.Next() {
+		var parent string
+		if err := rows.Scan(&parent); err != nil {
+			return nil, err
+		}
+		parents = append(parents, parent)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return parents, nil
+}
+
+// ListAllSnapshotParents returns the ordered parents of every snapshot,
+// keyed by snapshot hash.
+func ListAllSnapshotParents(db DBTX) (map[string][]string, error) {
+	rows, err := db.Query("SELECT snapshot_hash, parent_hash FROM snapshot_parents ORDER BY snapshot_hash, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := map[string][]string{}
+	for rows.Next() {
+		var snapshotHash, parent string
+		if err := rows.Scan(&snapshotHash, &parent); err != nil {
+			return nil, err
+		}
+		parents[snapshotHash] = append(parents[snapshotHash], parent)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return parents, nil
+}
+
+// ListAncestors returns every snapshot reachable from hash through any
+// parent, including hash itself.
+func ListAncestors(db DBTX, hash string) ([]string, error) {
+	rows, err := db.Query(`
+		WITH RECURSIVE ancestors(hash) AS (
+			SELECT ?
+			UNION
+			SELECT sp.parent_hash
+			FROM snapshot_parents sp
+			JOIN ancestors a ON sp.snapshot_hash = a.hash
+		)
+		SELECT hash FROM ancestors`,
+		hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
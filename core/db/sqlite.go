@@ -57,6 +57,12 @@ func OpenDB(path string) (*sql.DB, error) {
 		return nil, fmt.Errorf("%w: %v", errors.ErrDatabasePingFailed, err)
 	}
 
+	// Bring repositories created by older versions up to date.
+	if err := ApplySchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
 
@@ -94,3 +100,10 @@ func ApplySchema(db *sql.DB) error {
 
 	return nil
 }
+
+// Vacuum rebuilds the database file to return freed pages to the OS.
+// It cannot run inside a transaction.
+func Vacuum(db *sql.DB) error {
+	_, err := db.Exec("VACUUM")
+	return err
+}
@@ -1,17 +1,37 @@
 package db
 
 import (
+	"database/sql"
 	"time"
 )
 
+// Stage is a staged path. ObjectHash is the staged content, or empty when
+// the content is taken from the workspace. Deleted paths leave the next
+// snapshot.
 type Stage struct {
-	Path     string
-	StagedAt time.Time
+	Path       string
+	ObjectHash string
+	Deleted    bool
+	StagedAt   time.Time
 }
 
-func AddStageFile(db DBTX, path string) error {
+func AddStageFile(db DBTX, path, objectHash string) error {
+	var hash *string
+	if objectHash != "" {
+		hash = &objectHash
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO staged_files (path, object_hash) VALUES (?, ?) ON CONFLICT(path) DO UPDATE SET object_hash = excluded.object_hash, deleted = 0, staged_at = (strftime('%s', 'now'))",
+		path,
+		hash,
+	)
+	return err
+}
+
+func StageDeletion(db DBTX, path string) error {
 	_, err := db.Exec(
-		"INSERT INTO staged_files (path) VALUES (?) ON CONFLICT(path) DO UPDATE SET staged_at = (strftime('%s', 'now'))",
+		"INSERT INTO staged_files (path, deleted) VALUES (?, 1) ON CONFLICT(path) DO UPDATE SET object_hash = NULL, deleted = 1, staged_at = (strftime('%s', 'now'))",
 		path,
 	)
 	return err
@@ -32,7 +52,7 @@ func ClearStage(db DBTX) error {
 
 func GetStageFiles(db DBTX) ([]Stage, error) {
 	rows, err := db.Query(
-		"SELECT path, staged_at FROM staged_files ORDER BY staged_at",
+		"SELECT path, object_hash, deleted, staged_at FROM staged_files ORDER BY staged_at",
 	)
 	if err != nil {
 		return nil, err
@@ -43,12 +63,14 @@ func GetStageFiles(db DBTX) ([]Stage, error) {
 
 	for rows.Next() {
 		var s Stage
+		var hash sql.NullString
 		var ts int64
 
-		if err := rows.Scan(&s.Path, &ts); err != nil {
+		if err := rows.Scan(&s.Path, &hash, &s.Deleted, &ts); err != nil {
 			return nil, err
 		}
 
+		s.ObjectHash = hash.String
 		s.StagedAt = time.Unix(ts, 0)
 		stages = append(stages, s)
 	}
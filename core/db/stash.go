@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// Stash is a saved workspace. SnapshotHash holds the workspace tree,
+// BaseHash the HEAD snapshot it was taken on (nil on an unborn branch).
+type Stash struct {
+	ID           int64
+	SnapshotHash string
+	BaseHash     *string
+	Branch       string
+	Message      string
+	CreatedAt    int64
+}
+
+// CreateStash records a stash with the stage rows it saved, so popping it
+// restores the staged content and deletions as they were.
+func CreateStash(db DBTX, snapshotHash string, baseHash *string, branch, message string, staged []Stage) (int64, error) {
+	res, err := db.Exec(
+		"INSERT INTO stash (snapshot_hash, base_hash, branch, message) VALUES (?, ?, ?, ?)",
+		snapshotHash,
+		baseHash,
+		branch,
+		message,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range staged {
+		var hash *string
+		if f.ObjectHash != "" {
+			hash = &f.ObjectHash
+		}
+		if _, err := db.Exec(
+			"INSERT OR IGNORE INTO stash_staged_files (stash_id, path, object_hash, deleted) VALUES (?, ?, ?, ?)",
+			id,
+			f.Path,
+			hash,
+			f.Deleted,
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// ListStashes returns stashes newest first, so index 0 is the latest.
+func ListStashes(db DBTX) ([]Stash, error) {
+	rows, err := db.Query("SELECT id, snapshot_hash, base_hash, branch, message, created_at FROM stash ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stashes []Stash
+	for rows.Next() {
+		var (
+			s    Stash
+			base sql.NullString
+		)
+
+		if err := rows.Scan(&s.ID, &s.SnapshotHash, &base, &s.Branch, &s.Message, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if base.Valid {
+			s.BaseHash = &base.String
+		}
+
+		stashes = append(stashes, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stashes, nil
+}
+
+func ListStashStagedFiles(db DBTX, id int64) ([]Stage, error) {
+	rows, err := db.Query("SELECT path, object_hash, deleted FROM stash_staged_files WHERE stash_id = ? ORDER BY path", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var staged []Stage
+	for rows.Next() {
+		var (
+			s    Stage
+			hash sql.NullString
+		)
+		if err := rows.Scan(&s.Path, &hash, &s.Deleted); err != nil {
+			return nil, err
+		}
+		s.ObjectHash = hash.String
+		staged = append(staged, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return staged, nil
+}
+
+func DeleteStash(db DBTX, id int64) error {
+	if _, err := db.Exec("DELETE FROM stash_staged_files WHERE stash_id = ?", id); err != nil {
+		return err
+	}
+
+	res, err := db.Exec("DELETE FROM stash WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrStashNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// Tag is a named pointer to a snapshot. Lightweight tags have no message.
+type Tag struct {
+	Name         string
+	SnapshotHash string
+	Message      *string
+	Tagger       *string
+	CreatedAt    int64
+}
+
+func CreateTag(db DBTX, name, snapshotHash string, message, tagger *string) error {
+	_, err := db.Exec(
+		"INSERT INTO tags (name, snapshot_hash, message, tagger) VALUES (?, ?, ?, ?)",
+		name,
+		snapshotHash,
+		message,
+		tagger,
+	)
+	return err
+}
+
+func GetTag(db DBTX, name string) (*Tag, error) {
+	var (
+		tag     Tag
+		message sql.NullString
+		tagger  sql.NullString
+	)
+
+	err := db.QueryRow(
+		"SELECT name, snapshot_hash, message, tagger, created_at FROM tags WHERE name = ?",
+		name,
+	).Scan(&tag.Name, &tag.SnapshotHash, &message, &tagger, &tag.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrTagNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Valid {
+		tag.Message = &message.String
+	}
+	if tagger.Valid {
+		tag.Tagger = &tagger.String
+	}
+
+	return &tag, nil
+}
+
+func ListTags(db DBTX) ([]Tag, error) {
+	rows, err := db.Query("SELECT name, snapshot_hash, message, tagger, created_at FROM tags ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var (
+			tag     Tag
+			message sql.NullString
+			tagger  sql.NullString
+		)
+
+		if err := rows.Scan(&tag.Name, &tag.SnapshotHash, &message, &tagger, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if message.Valid {
+			tag.Message = &message.String
+		}
+		if tagger.Valid {
+			tag.Tagger = &tagger.String
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func DeleteTag(db DBTX, name string) error {
+	res, err := db.Exec(
+		"DELETE FROM tags WHERE name = ?",
+		name,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrTagNotFound
+	}
+
+	return nil
+}
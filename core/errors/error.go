@@ -12,5 +12,13 @@ var (
 	ErrRefNotFound            = errors.New("ref not found")
 	ErrSnapshotNotFound       = errors.New("snapshot not found")
 	ErrObjectNotFound         = errors.New("object not found")
+	ErrTagNotFound            = errors.New("tag not found")
+	ErrStashNotFound          = errors.New("stash not found")
+	ErrRevisionNotFound       = errors.New("revision not found")
+	ErrAmbiguousRevision      = errors.New("ambiguous revision")
 	ErrIgnoreFileNotFound     = errors.New("ignore file not found")
+	ErrUnsupportedCodec       = errors.New("unsupported object codec")
+	ErrCorruptDelta           = errors.New("corrupt delta object")
+	ErrCorruptObject          = errors.New("corrupt object")
+	ErrBinaryFile             = errors.New("binary file")
 )
@@ -2,7 +2,10 @@ module github.com/greedypanda0/kuro/core
 
 go 1.24.6
 
-require modernc.org/sqlite v1.44.2
+require (
+	github.com/pmezard/go-difflib v1.0.0
+	modernc.org/sqlite v1.44.2
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
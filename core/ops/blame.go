@@ -0,0 +1,155 @@
+package ops
+
+import (
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// BlameLine attributes one line of a file to the snapshot that last
+// changed it. OrigLine is the 1-based line number in that snapshot.
+type BlameLine struct {
+	Snapshot *db.Snapshot
+	OrigLine int
+	Text     string
+}
+
+// Blame attributes every line of path as of the snapshot rev to the
+// snapshot that introduced it, following all parents. A line found in a
+// parent is passed on to it, trying parents in order; only lines no
+// parent has are attributed to a merge. It returns ErrDataNotFound when
+// rev has no such path and ErrBinaryFile for binary content.
+func Blame(database db.DBTX, rev, path string) ([]BlameLine, error) {
+	file, err := db.GetSnapshotFile(database, rev, path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := objectContent(database, file.ObjectHash)
+	if err != nil {
+		return nil, err
+	}
+	if !IsText(content) {
+		return nil, errors.ErrBinaryFile
+	}
+
+	parentsOf, err := db.ListAllSnapshotParents(database)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(content)
+	result := make([]BlameLine, len(lines))
+
+	// A suspect is an unattributed line: its index in the result and in
+	// the version of the file being examined.
+	type suspect struct{ line, pos int }
+	type version struct {
+		objectHash string
+		lines      []string
+		suspects   []suspect
+	}
+
+	start := &version{objectHash: file.ObjectHash, lines: lines}
+	for i := range lines {
+		start.suspects = append(start.suspects, suspect{i, i})
+	}
+	pending := map[string]*version{rev: start}
+	remaining := len(lines)
+
+	for _, hash := range childrenFirst(rev, parentsOf) {
+		if remaining == 0 {
+			break
+		}
+		v, ok := pending[hash]
+		if !ok {
+			continue
+		}
+		delete(pending, hash)
+
+		suspects := v.suspects
+		for _, parent := range parentsOf[hash] {
+			if len(suspects) == 0 {
+				break
+			}
+			parentFile, err := db.GetSnapshotFile(database, parent, path)
+			if err == errors.ErrDataNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			pv, ok := pending[parent]
+			if !ok {
+				pv = &version{objectHash: parentFile.ObjectHash}
+			}
+			// An unchanged file passes every line on.
+			if parentFile.ObjectHash == v.objectHash {
+				pv.lines = v.lines
+				pv.suspects = append(pv.suspects, suspects...)
+				pending[parent] = pv
+				suspects = nil
+				break
+			}
+			if pv.lines == nil {
+				parentContent, err := objectContent(database, parentFile.ObjectHash)
+				if err != nil {
+					return nil, err
+				}
+				pv.lines = splitLines(parentContent)
+			}
+
+			match := matchLines(v.lines, pv.lines)
+			var kept []suspect
+			for _, s := range suspects {
+				if match[s.pos] < 0 {
+					kept = append(kept, s)
+					continue
+				}
+				pv.suspects = append(pv.suspects, suspect{s.line, match[s.pos]})
+			}
+			if len(kept) < len(suspects) {
+				pending[parent] = pv
+			}
+			suspects = kept
+		}
+
+		// Lines no parent has were introduced here.
+		if len(suspects) == 0 {
+			continue
+		}
+		snapshot, err := db.GetSnapshot(database, hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range suspects {
+			result[s.line] = BlameLine{Snapshot: snapshot, OrigLine: s.pos + 1, Text: lines[s.line]}
+		}
+		remaining -= len(suspects)
+	}
+
+	return result, nil
+}
+
+// childrenFirst orders rev and its ancestors so that every snapshot comes
+// after all of its children.
+func childrenFirst(rev string, parentsOf map[string][]string) []string {
+	var order []string
+	visited := map[string]struct{}{}
+	var visit func(hash string)
+	visit = func(hash string) {
+		if _, ok := visited[hash]; ok {
+			return
+		}
+		visited[hash] = struct{}{}
+		for _, p := range parentsOf[hash] {
+			visit(p)
+		}
+		order = append(order, hash)
+	}
+	visit(rev)
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
@@ -0,0 +1,88 @@
+package ops
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+func TestBlameAttributesLines(t *testing.T) {
+	database := openTestDB(t)
+
+	// commitFiles stores each object's hash string as its content.
+	first := commitFiles(t, database, nil, "first", []Change{{Path: "f", ObjectHash: "one\ntwo\nthree\n"}})
+	second := commitFiles(t, database, &first, "second", []Change{{Path: "g", ObjectHash: "other\n"}})
+	third := commitFiles(t, database, &second, "third", []Change{{Path: "f", ObjectHash: "one\nTWO\nthree\nfour\n"}})
+
+	lines, err := Blame(database, third, "f")
+	if err != nil {
+		t.Fatalf("blame: %v", err)
+	}
+
+	want := []struct {
+		snapshot string
+		origLine int
+		text     string
+	}{
+		{first, 1, "one\n"},
+		{third, 2, "TWO\n"},
+		{first, 3, "three\n"},
+		{third, 4, "four\n"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d", len(want), len(lines))
+	}
+	for i, w := range want {
+		got := lines[i]
+		if got.Snapshot.Hash != w.snapshot || got.OrigLine != w.origLine || got.Text != w.text {
+			t.Fatalf("line %d: got %s:%d %q, want %s:%d %q", i+1, got.Snapshot.Hash, got.OrigLine, got.Text, w.snapshot, w.origLine, w.text)
+		}
+	}
+
+	if _, err := Blame(database, first, "g"); !stderrors.Is(err, errors.ErrDataNotFound) {
+		t.Fatalf("expected data not found, got %v", err)
+	}
+}
+
+func TestBlameFollowsMergedBranches(t *testing.T) {
+	database := openTestDB(t)
+
+	base := commitFiles(t, database, nil, "base", []Change{{Path: "f", ObjectHash: "one\ntwo\nthree\n"}})
+	main := commitFiles(t, database, &base, "main", []Change{{Path: "f", ObjectHash: "ONE\ntwo\nthree\n"}})
+	side := commitFiles(t, database, &base, "side", []Change{{Path: "f", ObjectHash: "one\ntwo\nTHREE\n"}})
+
+	merged := "ONE\ntwo\nTHREE\nmerged\n"
+	if err := db.CreateObject(database, merged, []byte(merged)); err != nil {
+		t.Fatalf("create object: %v", err)
+	}
+	merge, err := CommitTree(database, []string{main, side}, "merge", nil, []db.SnapshotFile{{Path: "f", ObjectHash: merged}})
+	if err != nil {
+		t.Fatalf("commit merge: %v", err)
+	}
+
+	lines, err := Blame(database, merge, "f")
+	if err != nil {
+		t.Fatalf("blame: %v", err)
+	}
+
+	want := []struct {
+		snapshot string
+		origLine int
+	}{
+		{main, 1},
+		{base, 2},
+		{side, 3},
+		{merge, 4},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %d", len(want), len(lines))
+	}
+	for i, w := range want {
+		got := lines[i]
+		if got.Snapshot.Hash != w.snapshot || got.OrigLine != w.origLine {
+			t.Fatalf("line %d: got %s:%d, want %s:%d", i+1, got.Snapshot.Hash, got.OrigLine, w.snapshot, w.origLine)
+		}
+	}
+}
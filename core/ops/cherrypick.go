@@ -0,0 +1,47 @@
+package ops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+// CherryPickTree applies the change a snapshot made to its first parent on
+// top of the given tree, as a three-way merge with the parent as base.
+func CherryPickTree(database db.DBTX, headFiles []db.SnapshotFile, target string) (*MergeResult, error) {
+	targetFiles, parentFiles, err := snapshotChange(database, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return MergeTrees(database, parentFiles, headFiles, targetFiles, "HEAD", target)
+}
+
+// CherryPickMessage keeps the original message and records where the
+// change came from.
+func CherryPickMessage(s *db.Snapshot) string {
+	return fmt.Sprintf("%s\n\n(cherry picked from snapshot %s)", strings.TrimRight(s.Message, "\n"), s.Hash)
+}
+
+// UnresolvedConflicts returns the conflicted paths that have not been
+// staged since the conflict was written, in the given order. Staging the
+// content or the deletion of a path marks it resolved.
+func UnresolvedConflicts(database db.DBTX, conflicts []string) ([]string, error) {
+	stageFiles, err := db.GetStageFiles(database)
+	if err != nil {
+		return nil, err
+	}
+	staged := make(map[string]struct{}, len(stageFiles))
+	for _, f := range stageFiles {
+		staged[f.Path] = struct{}{}
+	}
+
+	var unresolved []string
+	for _, path := range conflicts {
+		if _, ok := staged[path]; !ok {
+			unresolved = append(unresolved, path)
+		}
+	}
+	return unresolved, nil
+}
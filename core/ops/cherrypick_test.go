@@ -0,0 +1,76 @@
+package ops
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestCherryPickTreeAppliesOnlyThatChange(t *testing.T) {
+	database := openTestDB(t)
+
+	base := commitFiles(t, database, nil, "base", []Change{{Path: "a", ObjectHash: "a1"}})
+	feature := commitFiles(t, database, &base, "feature one", []Change{{Path: "b", ObjectHash: "b1"}})
+	picked := commitFiles(t, database, &feature, "feature two", []Change{
+		{Path: "a", ObjectHash: "a2"},
+		{Path: "c", ObjectHash: "c1"},
+	})
+	main := commitFiles(t, database, &base, "main", []Change{{Path: "d", ObjectHash: "d1"}})
+
+	headFiles, err := db.ListSnapshotFiles(database, main)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+
+	result, err := CherryPickTree(database, headFiles, picked)
+	if err != nil {
+		t.Fatalf("cherry-pick: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", result.Conflicts)
+	}
+
+	got := treeMap(result.Files)
+	want := map[string]string{"a": "a2", "c": "c1", "d": "d1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("picked tree: got %v, want %v", got, want)
+	}
+
+	snapshot, err := db.GetSnapshot(database, picked)
+	if err != nil {
+		t.Fatalf("get snapshot: %v", err)
+	}
+	if msg := CherryPickMessage(snapshot); !strings.HasSuffix(msg, "(cherry picked from snapshot "+picked+")") {
+		t.Fatalf("missing trailer: %q", msg)
+	}
+}
+
+func TestUnresolvedConflictsUntilStaged(t *testing.T) {
+	database := openTestDB(t)
+	conflicts := []string{"a", "b", "c"}
+
+	unresolved, err := UnresolvedConflicts(database, conflicts)
+	if err != nil {
+		t.Fatalf("unresolved conflicts: %v", err)
+	}
+	if !reflect.DeepEqual(unresolved, conflicts) {
+		t.Fatalf("unresolved: got %v, want %v", unresolved, conflicts)
+	}
+
+	if err := db.AddStageFile(database, "a", "a2"); err != nil {
+		t.Fatalf("stage a: %v", err)
+	}
+	if err := db.StageDeletion(database, "c"); err != nil {
+		t.Fatalf("stage deletion of c: %v", err)
+	}
+
+	unresolved, err = UnresolvedConflicts(database, conflicts)
+	if err != nil {
+		t.Fatalf("unresolved conflicts: %v", err)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(unresolved, want) {
+		t.Fatalf("unresolved: got %v, want %v", unresolved, want)
+	}
+}
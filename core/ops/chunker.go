@@ -0,0 +1,119 @@
+package ops
+
+import "io"
+
+// Chunk size bounds for content-defined chunking. Cut points depend only
+// on the surrounding bytes, so an edit moves at most a couple of chunk
+// boundaries and the rest of the file deduplicates against earlier
+// versions.
+const (
+	MinChunkSize = 16 << 10
+	AvgChunkSize = 64 << 10
+	MaxChunkSize = 256 << 10
+)
+
+// FastCDC normalised chunking: a stricter mask before the average size and
+// a looser one after it keeps chunk sizes close to AvgChunkSize.
+const (
+	chunkMaskSmall = uint64(1<<18-1) << (64 - 18)
+	chunkMaskLarge = uint64(1<<14-1) << (64 - 14)
+)
+
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x6b75726f)
+	for i := range table {
+		// splitmix64, so the table is fixed across builds and platforms.
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunker splits a stream into content-defined chunks using FastCDC.
+type Chunker struct {
+	r     io.Reader
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{r: r, buf: make([]byte, 2*MaxChunkSize)}
+}
+
+// Next returns the next chunk, or io.EOF once the stream is exhausted.
+// The returned slice is only valid until the following call.
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	n := cutPoint(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+
+	return chunk, nil
+}
+
+func (c *Chunker) fill() error {
+	if c.end-c.start >= MaxChunkSize || c.eof {
+		return nil
+	}
+
+	if c.start > 0 {
+		c.end = copy(c.buf, c.buf[c.start:c.end])
+		c.start = 0
+	}
+
+	for c.end < len(c.buf) && !c.eof {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cutPoint(data []byte) int {
+	n := len(data)
+	if n <= MinChunkSize {
+		return n
+	}
+	if n > MaxChunkSize {
+		n = MaxChunkSize
+	}
+
+	normal := AvgChunkSize
+	if normal > n {
+		normal = n
+	}
+
+	var fp uint64
+	i := MinChunkSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&chunkMaskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&chunkMaskLarge == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
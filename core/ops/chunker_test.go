@@ -0,0 +1,94 @@
+package ops
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func chunkAll(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+
+	var chunks [][]byte
+	chunker := NewChunker(bytes.NewReader(data))
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("next chunk: %v", err)
+		}
+		chunks = append(chunks, bytes.Clone(chunk))
+	}
+}
+
+func TestChunkerBoundariesSurviveInsertions(t *testing.T) {
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	original := chunkAll(t, data)
+	if !bytes.Equal(bytes.Join(original, nil), data) {
+		t.Fatalf("chunks do not reassemble the input")
+	}
+	for i, chunk := range original {
+		if len(chunk) > MaxChunkSize || (len(chunk) < MinChunkSize && i != len(original)-1) {
+			t.Fatalf("chunk %d has size %d outside bounds", i, len(chunk))
+		}
+	}
+
+	edited := append(append(bytes.Clone(data[:1<<20]), []byte("inserted bytes")...), data[1<<20:]...)
+	seen := map[string]struct{}{}
+	for _, chunk := range original {
+		seen[Hash(chunk)] = struct{}{}
+	}
+
+	shared := 0
+	for _, chunk := range chunkAll(t, edited) {
+		if _, ok := seen[Hash(chunk)]; ok {
+			shared++
+		}
+	}
+	if shared < len(original)-3 {
+		t.Fatalf("expected most chunks to be shared, got %d of %d", shared, len(original))
+	}
+}
+
+func TestStoreChunkedRoundTrip(t *testing.T) {
+	database := openTestDB(t)
+
+	data := make([]byte, 2<<20)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	hash, err := StoreChunked(database, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("store chunked: %v", err)
+	}
+	if hash != Hash(data) {
+		t.Fatalf("manifest hash must cover the full content")
+	}
+
+	chunks, err := db.ListObjectChunks(database, hash)
+	if err != nil || len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d (%v)", len(chunks), err)
+	}
+
+	var buf bytes.Buffer
+	if err := db.WriteObject(database, hash, &buf); err != nil {
+		t.Fatalf("write object: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("streamed content did not round-trip")
+	}
+
+	issues, err := Verify(database)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+}
@@ -0,0 +1,502 @@
+package ops
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ChangeStatus classifies a path in a tree comparison.
+type ChangeStatus byte
+
+const (
+	StatusAdded    ChangeStatus = 'A'
+	StatusModified ChangeStatus = 'M'
+	StatusDeleted  ChangeStatus = 'D'
+	StatusRenamed  ChangeStatus = 'R'
+	StatusCopied   ChangeStatus = 'C'
+)
+
+// DefaultRenameThreshold is the minimum similarity, in percent of matching
+// lines, for an added file to count as a rename or copy.
+const DefaultRenameThreshold = 50
+
+// renameLimit caps the number of deleted and added pairs compared by
+// content; larger change sets only get exact rename detection.
+const renameLimit = 10000
+
+func (s ChangeStatus) String() string {
+	return string(s)
+}
+
+// FileChange is a path whose object differs between two trees. OldHash is
+// empty for added files and NewHash for deleted ones. Renames and copies
+// record their source in OldPath and the percentage of matching lines in
+// Similarity.
+type FileChange struct {
+	Path       string
+	Status     ChangeStatus
+	OldHash    string
+	NewHash    string
+	OldPath    string
+	Similarity int
+}
+
+// RenameOptions configures DetectRenames. A Threshold above 100 limits
+// detection to identical content.
+type RenameOptions struct {
+	Threshold int
+	Copies    bool
+}
+
+// DiffTrees returns the paths that differ between two trees, sorted by
+// path.
+func DiffTrees(old, new []db.SnapshotFile) []FileChange {
+	oldMap := treeMap(old)
+	newMap := treeMap(new)
+
+	var changes []FileChange
+	for path, newHash := range newMap {
+		oldHash, ok := oldMap[path]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: path, Status: StatusAdded, NewHash: newHash})
+		case oldHash != newHash:
+			changes = append(changes, FileChange{Path: path, Status: StatusModified, OldHash: oldHash, NewHash: newHash})
+		}
+	}
+	for path, oldHash := range oldMap {
+		if _, ok := newMap[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Status: StatusDeleted, OldHash: oldHash})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// DetectRenames pairs deleted and added files into renames, first by
+// identical object hash and then by line similarity. With Copies, added
+// files that match a file of the old tree become copies of it; similarity
+// copies are only looked for among modified files. Old content is read
+// from oldSource and new content from newSource.
+func DetectRenames(changes []FileChange, old []db.SnapshotFile, oldSource, newSource ContentSource, opts RenameOptions) ([]FileChange, error) {
+	var deleted, added []int
+	for i, c := range changes {
+		switch c.Status {
+		case StatusDeleted:
+			deleted = append(deleted, i)
+		case StatusAdded:
+			added = append(added, i)
+		}
+	}
+	if len(added) == 0 {
+		return changes, nil
+	}
+
+	paired := map[int]FileChange{} // added index -> rename or copy
+	used := map[int]bool{}         // deleted indexes already renamed
+
+	// Identical content is always a rename.
+	byHash := map[string][]int{}
+	for _, d := range deleted {
+		byHash[changes[d].OldHash] = append(byHash[changes[d].OldHash], d)
+	}
+	for _, a := range added {
+		for _, d := range byHash[changes[a].NewHash] {
+			if !used[d] {
+				used[d] = true
+				paired[a] = renamed(changes[d], changes[a], StatusRenamed, 100)
+				break
+			}
+		}
+	}
+
+	cache := map[string][]string{}
+	lines := func(source ContentSource, path, hash string) ([]string, bool, error) {
+		key := path + "\x00" + hash
+		if l, ok := cache[key]; ok {
+			return l, l != nil, nil
+		}
+		content, err := source(path, hash)
+		if stderrors.Is(err, errors.ErrBinaryFile) {
+			cache[key] = nil
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		l := splitLines(content)
+		if l == nil {
+			l = []string{}
+		}
+		cache[key] = l
+		return l, true, nil
+	}
+
+	// score compares the old side of source with the new side of target,
+	// reporting false when either is binary.
+	score := func(source, target FileChange) (int, bool, error) {
+		a, ok, err := lines(oldSource, source.Path, source.OldHash)
+		if err != nil || !ok {
+			return 0, false, err
+		}
+		b, ok, err := lines(newSource, target.Path, target.NewHash)
+		if err != nil || !ok {
+			return 0, false, err
+		}
+		return similarity(a, b), true, nil
+	}
+
+	if opts.Threshold <= 100 && len(deleted)*len(added) <= renameLimit {
+		type candidate struct {
+			source, target, score int
+		}
+		var candidates []candidate
+		for _, a := range added {
+			if _, ok := paired[a]; ok {
+				continue
+			}
+			for _, d := range deleted {
+				if used[d] {
+					continue
+				}
+				sc, ok, err := score(changes[d], changes[a])
+				if err != nil {
+					return nil, err
+				}
+				if ok && sc >= opts.Threshold {
+					candidates = append(candidates, candidate{source: d, target: a, score: sc})
+				}
+			}
+		}
+
+		// Pair the most similar files first.
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].score > candidates[j].score
+		})
+		for _, c := range candidates {
+			if used[c.source] {
+				continue
+			}
+			if _, ok := paired[c.target]; ok {
+				continue
+			}
+			used[c.source] = true
+			paired[c.target] = renamed(changes[c.source], changes[c.target], StatusRenamed, c.score)
+		}
+	}
+
+	if opts.Copies {
+		oldByHash := map[string]string{}
+		for _, f := range old {
+			if _, ok := oldByHash[f.ObjectHash]; !ok {
+				oldByHash[f.ObjectHash] = f.Path
+			}
+		}
+		var modified []int
+		for i, c := range changes {
+			if c.Status == StatusModified {
+				modified = append(modified, i)
+			}
+		}
+
+		for _, a := range added {
+			if _, ok := paired[a]; ok {
+				continue
+			}
+			if path, ok := oldByHash[changes[a].NewHash]; ok {
+				source := FileChange{Path: path, OldHash: changes[a].NewHash}
+				paired[a] = renamed(source, changes[a], StatusCopied, 100)
+				continue
+			}
+			if opts.Threshold > 100 || len(modified)*len(added) > renameLimit {
+				continue
+			}
+			bestSource, bestScore := -1, 0
+			for _, m := range modified {
+				sc, ok, err := score(changes[m], changes[a])
+				if err != nil {
+					return nil, err
+				}
+				if ok && sc >= opts.Threshold && sc > bestScore {
+					bestSource, bestScore = m, sc
+				}
+			}
+			if bestSource >= 0 {
+				paired[a] = renamed(changes[bestSource], changes[a], StatusCopied, bestScore)
+			}
+		}
+	}
+
+	result := make([]FileChange, 0, len(changes))
+	for i, c := range changes {
+		if c.Status == StatusDeleted && used[i] {
+			continue
+		}
+		if r, ok := paired[i]; ok {
+			c = r
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func renamed(source, target FileChange, status ChangeStatus, score int) FileChange {
+	return FileChange{
+		Path:       target.Path,
+		Status:     status,
+		OldHash:    source.OldHash,
+		NewHash:    target.NewHash,
+		OldPath:    source.Path,
+		Similarity: score,
+	}
+}
+
+// similarity returns the percentage of lines two versions share.
+func similarity(a, b []string) int {
+	if len(a)+len(b) == 0 {
+		return 100
+	}
+	matched := 0
+	for _, m := range matchLines(a, b) {
+		if m >= 0 {
+			matched++
+		}
+	}
+	return 200 * matched / (len(a) + len(b))
+}
+
+// LineStats counts the lines added and removed between two versions of a
+// text file.
+func LineStats(old, new []byte) (added, removed int) {
+	oldLines := splitLines(old)
+	newLines := splitLines(new)
+
+	matched := 0
+	for _, m := range matchLines(oldLines, newLines) {
+		if m >= 0 {
+			matched++
+		}
+	}
+
+	return len(newLines) - matched, len(oldLines) - matched
+}
+
+// ContentSource loads the content of one side of a comparison. It returns
+// ErrBinaryFile for content that should not be diffed line by line.
+type ContentSource func(path, hash string) ([]byte, error)
+
+// ObjectSource reads content from the object store. Chunked objects are
+// reported as binary without being loaded.
+func ObjectSource(database db.DBTX) ContentSource {
+	return func(path, hash string) ([]byte, error) {
+		chunks, err := db.ListObjectChunks(database, hash)
+		if err != nil {
+			return nil, err
+		}
+		if chunks != nil {
+			return nil, errors.ErrBinaryFile
+		}
+		content, err := objectContent(database, hash)
+		if err != nil {
+			return nil, err
+		}
+		if !IsText(content) {
+			return nil, errors.ErrBinaryFile
+		}
+		return content, nil
+	}
+}
+
+// WorkspaceSource reads content from the files under root. Files at or
+// above ChunkThreshold are reported as binary without being loaded.
+func WorkspaceSource(root string) ContentSource {
+	return func(path, hash string) ([]byte, error) {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() >= ChunkThreshold {
+			return nil, errors.ErrBinaryFile
+		}
+		content, err := os.ReadFile(absPath)
+		if err != nil {
+			return nil, err
+		}
+		if !IsText(content) {
+			return nil, errors.ErrBinaryFile
+		}
+		return content, nil
+	}
+}
+
+// HashWorkspace returns the tree formed by the workspace content of paths,
+// without storing any objects. Paths missing from the workspace are left
+// out.
+func HashWorkspace(root string, paths []string) ([]db.SnapshotFile, error) {
+	return hashPaths(paths, func(path string) (string, error) {
+		return HashFile(filepath.Join(root, filepath.FromSlash(path)))
+	})
+}
+
+func hashPaths(paths []string, hashFile func(path string) (string, error)) ([]db.SnapshotFile, error) {
+	files := make([]db.SnapshotFile, 0, len(paths))
+	seen := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+
+		hash, err := hashFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, db.SnapshotFile{Path: path, ObjectHash: hash})
+	}
+	return files, nil
+}
+
+// FilterChanges keeps the changes to the given files or to anything below
+// the given directories, matching either side of a rename. No paths keeps
+// everything.
+func FilterChanges(changes []FileChange, paths []string) []FileChange {
+	if len(paths) == 0 {
+		return changes
+	}
+
+	var filtered []FileChange
+	for _, c := range changes {
+		for _, p := range paths {
+			p = strings.TrimSuffix(p, "/")
+			if p == "" || p == "." || underPath(c.Path, p) || (c.OldPath != "" && underPath(c.OldPath, p)) {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func underPath(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// LoadChange loads both sides of a change. Either side may report
+// ErrBinaryFile.
+func LoadChange(c FileChange, old, new ContentSource) ([]byte, []byte, error) {
+	var oldContent, newContent []byte
+	var err error
+	if c.OldHash != "" {
+		oldPath := c.Path
+		if c.OldPath != "" {
+			oldPath = c.OldPath
+		}
+		if oldContent, err = old(oldPath, c.OldHash); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.NewHash != "" {
+		if newContent, err = new(c.Path, c.NewHash); err != nil {
+			return nil, nil, err
+		}
+	}
+	return oldContent, newContent, nil
+}
+
+// WriteDiff writes a unified diff of each change, reading the old side
+// from old and the new side from new.
+func WriteDiff(w io.Writer, changes []FileChange, old, new ContentSource) error {
+	for _, c := range changes {
+		oldContent, newContent, err := LoadChange(c, old, new)
+		if stderrors.Is(err, errors.ErrBinaryFile) {
+			oldPath := c.Path
+			if c.OldPath != "" {
+				oldPath = c.OldPath
+			}
+			if _, err := fmt.Fprintf(w, "%sBinary files a/%s and b/%s differ\n\n", DiffHeader(c), oldPath, c.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Path, err)
+		}
+		if _, err := io.WriteString(w, UnifiedDiff(c, oldContent, newContent)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnifiedDiff renders one change as a unified diff with three lines of
+// context. Line endings are normalised to LF.
+func UnifiedDiff(c FileChange, oldContent, newContent []byte) string {
+	from, to := "a/"+c.Path, "b/"+c.Path
+	switch c.Status {
+	case StatusAdded:
+		from = "/dev/null"
+	case StatusDeleted:
+		to = "/dev/null"
+	case StatusRenamed, StatusCopied:
+		from = "a/" + c.OldPath
+	}
+
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(oldContent),
+		B:        diffLines(newContent),
+		FromFile: from,
+		ToFile:   to,
+		Context:  3,
+	})
+
+	header := DiffHeader(c)
+	if c.OldPath != "" && (err != nil || text == "") {
+		return header + "\n"
+	}
+	if err != nil || text == "" {
+		return fmt.Sprintf("%s--- %s\n+++ %s\n\n", header, from, to)
+	}
+
+	return header + text + "\n"
+}
+
+// DiffHeader returns the lines introducing one change in a diff.
+func DiffHeader(c FileChange) string {
+	switch c.Status {
+	case StatusRenamed, StatusCopied:
+		verb := "rename"
+		if c.Status == StatusCopied {
+			verb = "copy"
+		}
+		return fmt.Sprintf("diff --kuro %s -> %s\nsimilarity index %d%%\n%s from %s\n%s to %s\n",
+			c.OldPath, c.Path, c.Similarity, verb, c.OldPath, verb, c.Path)
+	default:
+		return fmt.Sprintf("diff --kuro %s\n", c.Path)
+	}
+}
+
+func diffLines(content []byte) []string {
+	lines := splitLines([]byte(strings.ReplaceAll(string(content), "\r\n", "\n")))
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	return lines
+}
@@ -0,0 +1,140 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestDiffTrees(t *testing.T) {
+	old := []db.SnapshotFile{
+		{Path: "keep", ObjectHash: "k"},
+		{Path: "edit", ObjectHash: "e1"},
+		{Path: "gone", ObjectHash: "g"},
+	}
+	new := []db.SnapshotFile{
+		{Path: "keep", ObjectHash: "k"},
+		{Path: "edit", ObjectHash: "e2"},
+		{Path: "added", ObjectHash: "a"},
+	}
+
+	got := DiffTrees(old, new)
+	want := []FileChange{
+		{Path: "added", Status: StatusAdded, NewHash: "a"},
+		{Path: "edit", Status: StatusModified, OldHash: "e1", NewHash: "e2"},
+		{Path: "gone", Status: StatusDeleted, OldHash: "g"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diff trees: got %+v, want %+v", got, want)
+	}
+}
+
+func TestLineStats(t *testing.T) {
+	added, removed := LineStats([]byte("a\nb\nc\n"), []byte("a\nB\nc\nd\n"))
+	if added != 2 || removed != 1 {
+		t.Fatalf("line stats: got +%d -%d, want +2 -1", added, removed)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	database := openTestDB(t)
+
+	for hash, content := range map[string]string{
+		"old":    "a\nb\nc\n",
+		"new":    "a\nB\nc",
+		"binary": "\x00\x01",
+	} {
+		if err := db.CreateObject(database, hash, []byte(content)); err != nil {
+			t.Fatalf("create object: %v", err)
+		}
+	}
+
+	changes := []FileChange{
+		{Path: "f", Status: StatusModified, OldHash: "old", NewHash: "new"},
+		{Path: "bin", Status: StatusAdded, NewHash: "binary"},
+	}
+
+	var out strings.Builder
+	source := ObjectSource(database)
+	if err := WriteDiff(&out, changes, source, source); err != nil {
+		t.Fatalf("write diff: %v", err)
+	}
+
+	want := "diff --kuro f\n" +
+		"--- a/f\n" +
+		"+++ b/f\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" a\n" +
+		"-b\n" +
+		"+B\n" +
+		" c\n" +
+		"\n" +
+		"diff --kuro bin\n" +
+		"Binary files a/bin and b/bin differ\n\n"
+	if out.String() != want {
+		t.Fatalf("diff:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestDetectRenamesAndCopies(t *testing.T) {
+	database := openTestDB(t)
+
+	// commitFiles stores each object's hash string as its content.
+	old := "one\ntwo\nthree\nfour\n"
+	edited := "one\ntwo\nthree\nFOUR\n"
+	base := commitFiles(t, database, nil, "base", []Change{
+		{Path: "moved", ObjectHash: old},
+		{Path: "edited", ObjectHash: "a\nb\nc\nd\n"},
+		{Path: "kept", ObjectHash: "kept\n"},
+		{Path: "unrelated", ObjectHash: "x\ny\n"},
+	})
+	next := commitFiles(t, database, &base, "next", []Change{
+		{Path: "moved", Deleted: true},
+		{Path: "unrelated", Deleted: true},
+		{Path: "renamed", ObjectHash: edited},
+		{Path: "edited", ObjectHash: "a\nb\nc\nD\n"},
+		{Path: "copied", ObjectHash: "kept\n"},
+		{Path: "fresh", ObjectHash: "p\nq\n"},
+	})
+
+	oldFiles, err := db.ListSnapshotFiles(database, base)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	newFiles, err := db.ListSnapshotFiles(database, next)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+
+	source := ObjectSource(database)
+	changes, err := DetectRenames(DiffTrees(oldFiles, newFiles), oldFiles, source, source, RenameOptions{Threshold: DefaultRenameThreshold, Copies: true})
+	if err != nil {
+		t.Fatalf("detect renames: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, c := range changes {
+		got[c.Path] = fmt.Sprintf("%s %s %d", c.Status, c.OldPath, c.Similarity)
+	}
+	want := map[string]string{
+		"copied":    "C kept 100",
+		"edited":    "M  0",
+		"fresh":     "A  0",
+		"renamed":   "R moved 75",
+		"unrelated": "D  0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes: got %v, want %v", got, want)
+	}
+
+	history, err := FollowHistory(database, next, "renamed", RenameOptions{Threshold: DefaultRenameThreshold})
+	if err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+	if len(history) != 2 || history[0].Hash != next || history[1].Hash != base {
+		t.Fatalf("follow: got %+v", history)
+	}
+}
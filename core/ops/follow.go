@@ -0,0 +1,80 @@
+package ops
+
+import (
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// FollowHistory returns the snapshots on the first-parent chain of tip
+// that changed path, newest first. Where the file was added by a rename,
+// the walk carries on under its previous name.
+func FollowHistory(database db.DBTX, tip, path string, opts RenameOptions) ([]db.Snapshot, error) {
+	chain, err := db.QueryHistory(database, db.HistoryQuery{Tip: tip})
+	if err != nil {
+		return nil, err
+	}
+
+	var history []db.Snapshot
+	for _, s := range chain {
+		file, err := db.GetSnapshotFile(database, s.Hash, path)
+		if err == errors.ErrDataNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if s.ParentHash == nil {
+			history = append(history, s)
+			continue
+		}
+
+		parentFile, err := db.GetSnapshotFile(database, *s.ParentHash, path)
+		if err != nil && err != errors.ErrDataNotFound {
+			return nil, err
+		}
+		if err == nil {
+			if parentFile.ObjectHash != file.ObjectHash {
+				history = append(history, s)
+			}
+			continue
+		}
+
+		history = append(history, s)
+
+		from, err := renameSource(database, s.Hash, *s.ParentHash, path, opts)
+		if err != nil {
+			return nil, err
+		}
+		if from != "" {
+			path = from
+		}
+	}
+
+	return history, nil
+}
+
+// renameSource returns the path that path was renamed from between parent
+// and hash, or "" when it was newly added.
+func renameSource(database db.DBTX, hash, parent, path string, opts RenameOptions) (string, error) {
+	files, err := db.ListSnapshotFiles(database, hash)
+	if err != nil {
+		return "", err
+	}
+	parentFiles, err := db.ListSnapshotFiles(database, parent)
+	if err != nil {
+		return "", err
+	}
+
+	source := ObjectSource(database)
+	changes, err := DetectRenames(DiffTrees(parentFiles, files), parentFiles, source, source, RenameOptions{Threshold: opts.Threshold})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range changes {
+		if c.Path == path && c.Status == StatusRenamed {
+			return c.OldPath, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,184 @@
+package ops
+
+import (
+	"fmt"
+
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+type IssueKind string
+
+const (
+	IssueCorruptObject   IssueKind = "corrupt-object"
+	IssueCorruptSnapshot IssueKind = "corrupt-snapshot"
+	IssueDanglingRef     IssueKind = "dangling-ref"
+	IssueBrokenParent    IssueKind = "broken-parent"
+	IssueMissingObject   IssueKind = "missing-object"
+	IssueOrphanFile      IssueKind = "orphan-snapshot-file"
+)
+
+// Issue is a single integrity problem found by Verify.
+type Issue struct {
+	Kind    IssueKind
+	Subject string
+	Detail  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s: %s", i.Kind, i.Subject, i.Detail)
+}
+
+// Verify checks that objects (after resolving deltas) hash to their keys,
+// snapshots hash to their recomputed manifests, and that refs, tags,
+// stashes, parents, delta bases, chunks and snapshot files point at rows
+// that exist.
+func Verify(database db.DBTX) ([]Issue, error) {
+	var issues []Issue
+
+	objects, err := db.ListObjectInfo(database)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]struct{}, len(objects))
+	for _, info := range objects {
+		present[info.Hash] = struct{}{}
+	}
+
+	for _, info := range objects {
+		if info.BaseHash != nil {
+			if _, ok := present[*info.BaseHash]; !ok {
+				issues = append(issues, Issue{IssueMissingObject, info.Hash, "delta base " + *info.BaseHash})
+				continue
+			}
+		}
+
+		if info.Kind == db.KindChunks {
+			chunks, err := db.ListObjectChunks(database, info.Hash)
+			if err != nil {
+				issues = append(issues, Issue{IssueCorruptObject, info.Hash, err.Error()})
+				continue
+			}
+
+			missing := false
+			for _, chunk := range chunks {
+				if _, ok := present[chunk.Hash]; !ok {
+					issues = append(issues, Issue{IssueMissingObject, info.Hash, "chunk " + chunk.Hash})
+					missing = true
+				}
+			}
+			if missing {
+				continue
+			}
+		}
+
+		actual, err := HashObject(database, info.Hash)
+		if err != nil {
+			issues = append(issues, Issue{IssueCorruptObject, info.Hash, err.Error()})
+			continue
+		}
+		if actual != info.Hash {
+			issues = append(issues, Issue{IssueCorruptObject, info.Hash, "content hashes to " + actual})
+		}
+	}
+
+	snapshots, err := db.ListSnapshots(database)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]struct{}, len(snapshots))
+	for _, s := range snapshots {
+		known[s.Hash] = struct{}{}
+	}
+
+	for _, s := range snapshots {
+		parents, err := db.ListSnapshotParents(database, s.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, parent := range parents {
+			if _, ok := known[parent]; !ok {
+				issues = append(issues, Issue{IssueBrokenParent, s.Hash, "missing parent " + parent})
+			}
+		}
+
+		switch {
+		case s.ParentHash == nil && len(parents) > 0,
+			s.ParentHash != nil && (len(parents) == 0 || parents[0] != *s.ParentHash):
+			issues = append(issues, Issue{IssueBrokenParent, s.Hash, "parent_hash disagrees with snapshot_parents"})
+		}
+
+		files, err := db.ListSnapshotFiles(database, s.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if _, ok := present[f.ObjectHash]; !ok {
+				issues = append(issues, Issue{IssueMissingObject, s.Hash, fmt.Sprintf("%s -> %s", f.Path, f.ObjectHash)})
+			}
+		}
+
+		if actual := SnapshotHash(parents, s.Message, files); actual != s.Hash {
+			issues = append(issues, Issue{IssueCorruptSnapshot, s.Hash, "manifest hashes to " + actual})
+		}
+	}
+
+	orphans, err := db.ListOrphanSnapshotFiles(database)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range orphans {
+		issues = append(issues, Issue{IssueOrphanFile, f.SnapshotHash, f.Path})
+	}
+
+	refs, err := db.ListRefs(database)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if ref.SnapshotHash == nil {
+			continue
+		}
+		if _, ok := known[*ref.SnapshotHash]; !ok {
+			issues = append(issues, Issue{IssueDanglingRef, ref.Name, "points to missing snapshot " + *ref.SnapshotHash})
+		}
+	}
+
+	tags, err := db.ListTags(database)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		if _, ok := known[tag.SnapshotHash]; !ok {
+			issues = append(issues, Issue{IssueDanglingRef, "tag " + tag.Name, "points to missing snapshot " + tag.SnapshotHash})
+		}
+	}
+
+	stashes, err := db.ListStashes(database)
+	if err != nil {
+		return nil, err
+	}
+	for i, stash := range stashes {
+		if _, ok := known[stash.SnapshotHash]; !ok {
+			issues = append(issues, Issue{IssueDanglingRef, fmt.Sprintf("stash@{%d}", i), "points to missing snapshot " + stash.SnapshotHash})
+		}
+	}
+
+	head, err := db.GetConfig(database, "head")
+	if err != nil && err != errors.ErrDataNotFound {
+		return nil, err
+	}
+	if err == nil {
+		if _, err := db.GetRef(database, head); err == errors.ErrRefNotFound {
+			issues = append(issues, Issue{IssueDanglingRef, "HEAD", "points to missing ref " + head})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,60 @@
+package ops
+
+import (
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestVerifyReportsCorruption(t *testing.T) {
+	database := openTestDB(t)
+
+	content := []byte("hello\n")
+	objectHash := Hash(content)
+	if err := db.CreateObject(database, objectHash, content); err != nil {
+		t.Fatalf("create object: %v", err)
+	}
+
+	files := []db.SnapshotFile{{Path: "hello.txt", ObjectHash: objectHash}}
+	snapshot, err := CommitTree(database, nil, "init", nil, files)
+	if err != nil {
+		t.Fatalf("commit tree: %v", err)
+	}
+	if err := db.UpdateRef(database, "main", &snapshot, "commit", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+
+	issues, err := Verify(database)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("expected clean repository, got %v", issues)
+	}
+
+	if _, err := database.Exec("UPDATE objects SET content = ? WHERE hash = ?", []byte("tampered"), objectHash); err != nil {
+		t.Fatalf("tamper object: %v", err)
+	}
+	if _, err := database.Exec("UPDATE snapshot SET message = 'rewritten' WHERE hash = ?", snapshot); err != nil {
+		t.Fatalf("tamper snapshot: %v", err)
+	}
+	missing := "0000"
+	if err := db.SetRef(database, "ghost", &missing); err != nil {
+		t.Fatalf("set ref: %v", err)
+	}
+
+	issues, err = Verify(database)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	found := map[IssueKind]bool{}
+	for _, issue := range issues {
+		found[issue.Kind] = true
+	}
+	for _, kind := range []IssueKind{IssueCorruptObject, IssueCorruptSnapshot, IssueDanglingRef} {
+		if !found[kind] {
+			t.Fatalf("expected %s issue, got %v", kind, issues)
+		}
+	}
+}
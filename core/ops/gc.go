@@ -0,0 +1,223 @@
+package ops
+
+import (
+	"time"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+// DefaultGracePeriod protects recently written data from collection, so
+// work that is not referenced yet (or was just abandoned) survives a gc.
+const DefaultGracePeriod = 14 * 24 * time.Hour
+
+type GCOptions struct {
+	// DryRun reports what would be removed without deleting anything.
+	DryRun bool
+	// Cutoff is the newest creation time eligible for removal.
+	Cutoff time.Time
+	// Roots are extra snapshot hashes to keep alive besides refs, tags,
+	// stashes and the ref log.
+	Roots []string
+}
+
+type GCReport struct {
+	Snapshots      []string
+	Objects        []string
+	ReclaimedBytes int64
+}
+
+// Reachable returns the snapshots and objects reachable from all refs,
+// tags, stashes, ref log entries and the given extra roots, plus the
+// staged objects, stashed ones included.
+func Reachable(database db.DBTX, roots []string) (map[string]struct{}, map[string]struct{}, error) {
+	refs, err := db.ListRefs(database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ref := range refs {
+		if ref.SnapshotHash != nil {
+			roots = append(roots, *ref.SnapshotHash)
+		}
+	}
+
+	tags, err := db.ListTags(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tag := range tags {
+		roots = append(roots, tag.SnapshotHash)
+	}
+
+	stashes, err := db.ListStashes(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stashedStage []db.Stage
+	for _, stash := range stashes {
+		roots = append(roots, stash.SnapshotHash)
+
+		staged, err := db.ListStashStagedFiles(database, stash.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		stashedStage = append(stashedStage, staged...)
+	}
+
+	// Previous ref tips stay recoverable for as long as the ref log
+	// remembers them.
+	entries, err := db.ListRefLog(database, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		for _, hash := range []*string{entry.OldHash, entry.NewHash} {
+			if hash != nil {
+				roots = append(roots, *hash)
+			}
+		}
+	}
+
+	snapshots := map[string]struct{}{}
+	for _, root := range roots {
+		if _, seen := snapshots[root]; seen {
+			continue
+		}
+		ancestors, err := db.ListAncestors(database, root)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, h := range ancestors {
+			snapshots[h] = struct{}{}
+		}
+	}
+
+	objects := map[string]struct{}{}
+	for h := range snapshots {
+		files, err := db.ListSnapshotFiles(database, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range files {
+			objects[f.ObjectHash] = struct{}{}
+		}
+	}
+
+	stage, err := db.GetStageFiles(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range append(stage, stashedStage...) {
+		if f.ObjectHash != "" {
+			objects[f.ObjectHash] = struct{}{}
+		}
+	}
+
+	return snapshots, objects, nil
+}
+
+// CollectGarbage removes snapshots and objects that are unreachable and
+// older than the cutoff. Snapshots newer than the cutoff count as roots,
+// so their history and content are kept intact.
+func CollectGarbage(database db.DBTX, opts GCOptions) (*GCReport, error) {
+	cutoff := opts.Cutoff.Unix()
+
+	allSnapshots, err := db.ListSnapshots(database)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := append([]string{}, opts.Roots...)
+	for _, s := range allSnapshots {
+		if s.Timestamp > cutoff {
+			roots = append(roots, s.Hash)
+		}
+	}
+
+	keepSnapshots, keepObjects, err := Reachable(database, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{}
+
+	for _, s := range allSnapshots {
+		if _, ok := keepSnapshots[s.Hash]; ok {
+			continue
+		}
+		report.Snapshots = append(report.Snapshots, s.Hash)
+	}
+
+	objects, err := db.ListObjectInfo(database)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunked objects need their chunks and deltas need their whole base
+	// chain, even when those are no longer referenced by any snapshot.
+	bases := make(map[string]string, len(objects))
+	var manifests []string
+	for _, obj := range objects {
+		if obj.CreatedAt > cutoff {
+			keepObjects[obj.Hash] = struct{}{}
+		}
+		if obj.BaseHash != nil {
+			bases[obj.Hash] = *obj.BaseHash
+		}
+		if obj.Kind == db.KindChunks {
+			manifests = append(manifests, obj.Hash)
+		}
+	}
+	for _, hash := range manifests {
+		if _, ok := keepObjects[hash]; !ok {
+			continue
+		}
+		chunks, err := db.ListObjectChunks(database, hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			keepObjects[chunk.Hash] = struct{}{}
+		}
+	}
+	for hash := range keepObjects {
+		for base, ok := bases[hash]; ok; base, ok = bases[base] {
+			if _, seen := keepObjects[base]; seen {
+				break
+			}
+			keepObjects[base] = struct{}{}
+		}
+	}
+
+	for _, obj := range objects {
+		if _, ok := keepObjects[obj.Hash]; ok {
+			continue
+		}
+		report.Objects = append(report.Objects, obj.Hash)
+		report.ReclaimedBytes += obj.StoredSize
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, hash := range report.Snapshots {
+		if err := db.DeleteSnapshotFiles(database, hash); err != nil {
+			return nil, err
+		}
+		if err := db.DeleteSnapshotParents(database, hash); err != nil {
+			return nil, err
+		}
+		if err := db.DeleteSnapshot(database, hash); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, hash := range report.Objects {
+		if err := db.DeleteObject(database, hash); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,106 @@
+package ops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestCollectGarbageRemovesUnreachable(t *testing.T) {
+	database := openTestDB(t)
+
+	main := commitFiles(t, database, nil, "main", []Change{{Path: "a", ObjectHash: "keep"}})
+	if err := db.UpdateRef(database, "main", &main, "commit", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+	commitFiles(t, database, &main, "abandoned", []Change{{Path: "b", ObjectHash: "drop"}})
+
+	opts := GCOptions{DryRun: true, Cutoff: time.Now().Add(time.Hour)}
+	report, err := CollectGarbage(database, opts)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(report.Snapshots) != 1 || len(report.Objects) != 1 || report.Objects[0] != "drop" {
+		t.Fatalf("unexpected dry-run report: %+v", report)
+	}
+	if _, err := db.GetObject(database, "drop"); err != nil {
+		t.Fatalf("dry run must not delete: %v", err)
+	}
+
+	opts.DryRun = false
+	if _, err := CollectGarbage(database, opts); err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if _, err := db.GetObject(database, "drop"); err == nil {
+		t.Fatalf("expected unreachable object to be removed")
+	}
+	if _, err := db.GetObject(database, "keep"); err != nil {
+		t.Fatalf("reachable object removed: %v", err)
+	}
+}
+
+func TestCollectGarbageHonoursGracePeriod(t *testing.T) {
+	database := openTestDB(t)
+
+	commitFiles(t, database, nil, "fresh", []Change{{Path: "a", ObjectHash: "fresh"}})
+
+	report, err := CollectGarbage(database, GCOptions{Cutoff: time.Now().Add(-DefaultGracePeriod)})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if len(report.Snapshots) != 0 || len(report.Objects) != 0 {
+		t.Fatalf("expected fresh data to survive, got %+v", report)
+	}
+}
+
+func TestCollectGarbageKeepsRefLogTips(t *testing.T) {
+	database := openTestDB(t)
+
+	first := commitFiles(t, database, nil, "first", []Change{{Path: "a", ObjectHash: "first"}})
+	if err := db.UpdateRef(database, "main", &first, "commit: first", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+	second := commitFiles(t, database, &first, "second", []Change{{Path: "a", ObjectHash: "second"}})
+	if err := db.UpdateRef(database, "main", &second, "commit: second", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+	if err := db.UpdateRef(database, "main", &first, "reset: moving to first", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+
+	entries, err := db.ListRefLog(database, "main")
+	if err != nil {
+		t.Fatalf("list ref log: %v", err)
+	}
+	if len(entries) != 3 || entries[0].OldHash == nil || *entries[0].OldHash != second {
+		t.Fatalf("expected newest entry to record the abandoned tip, got %+v", entries)
+	}
+
+	report, err := CollectGarbage(database, GCOptions{Cutoff: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if len(report.Snapshots) != 0 || len(report.Objects) != 0 {
+		t.Fatalf("expected ref log to keep the old tip alive, got %+v", report)
+	}
+}
+
+func TestCollectGarbageKeepsStagedObjects(t *testing.T) {
+	database := openTestDB(t)
+
+	if err := db.CreateObject(database, "staged", []byte("staged\n")); err != nil {
+		t.Fatalf("create object: %v", err)
+	}
+	if err := db.AddStageFile(database, "a", "staged"); err != nil {
+		t.Fatalf("stage: %v", err)
+	}
+
+	report, err := CollectGarbage(database, GCOptions{Cutoff: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if len(report.Objects) != 0 {
+		t.Fatalf("expected staged object to survive, got %+v", report)
+	}
+}
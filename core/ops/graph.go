@@ -0,0 +1,272 @@
+package ops
+
+import (
+	"container/heap"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+// GraphLine is one line of an ASCII commit graph. Snapshot is nil for the
+// connector lines drawn where lanes fork or join.
+type GraphLine struct {
+	Snapshot *db.Snapshot
+	Prefix   string
+}
+
+// Graph lays out every snapshot reachable from tips as lanes of ASCII art,
+// newest first with children always above their parents.
+func Graph(database db.DBTX, tips []string) ([]GraphLine, error) {
+	snapshots, err := db.ListSnapshots(database)
+	if err != nil {
+		return nil, err
+	}
+	parents, err := db.ListAllSnapshotParents(database)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string]*db.Snapshot, len(snapshots))
+	for i := range snapshots {
+		byHash[snapshots[i].Hash] = &snapshots[i]
+	}
+
+	// Keep only parents that exist, and find what the tips reach.
+	known := func(hash string) []string {
+		var ps []string
+		for _, p := range parents[hash] {
+			if _, ok := byHash[p]; ok {
+				ps = append(ps, p)
+			}
+		}
+		return ps
+	}
+
+	reachable := map[string]struct{}{}
+	stack := []string{}
+	for _, tip := range tips {
+		if _, ok := byHash[tip]; ok {
+			stack = append(stack, tip)
+		}
+	}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := reachable[h]; ok {
+			continue
+		}
+		reachable[h] = struct{}{}
+		stack = append(stack, known(h)...)
+	}
+
+	children := make(map[string]int, len(reachable))
+	for h := range reachable {
+		for _, p := range known(h) {
+			children[p]++
+		}
+	}
+
+	ready := &snapshotHeap{}
+	for h := range reachable {
+		if children[h] == 0 {
+			heap.Push(ready, byHash[h])
+		}
+	}
+
+	order := make([]*db.Snapshot, 0, len(reachable))
+	for ready.Len() > 0 {
+		s := heap.Pop(ready).(*db.Snapshot)
+		order = append(order, s)
+		for _, p := range known(s.Hash) {
+			children[p]--
+			if children[p] == 0 {
+				heap.Push(ready, byHash[p])
+			}
+		}
+	}
+
+	return layoutGraph(order, known), nil
+}
+
+// layoutGraph assigns each snapshot a lane. A lane holds the hash it is
+// waiting for; lanes waiting for the same snapshot join above its row and
+// a merge forks new lanes for its extra parents below it.
+func layoutGraph(order []*db.Snapshot, parentsOf func(string) []string) []GraphLine {
+	var (
+		lanes []string
+		lines []GraphLine
+	)
+
+	for _, s := range order {
+		col := -1
+		var joining []int
+		for i, h := range lanes {
+			if h != s.Hash {
+				continue
+			}
+			if col < 0 {
+				col = i
+			} else {
+				joining = append(joining, i)
+			}
+		}
+		if col < 0 {
+			col = freeLane(&lanes)
+			lanes[col] = s.Hash
+		}
+
+		if len(joining) > 0 {
+			edges := straightEdges(lanes)
+			for _, j := range joining {
+				edges[j] = col
+				lanes[j] = ""
+			}
+			lines = append(lines, GraphLine{Prefix: renderEdges(edges, nil)})
+			lanes = trimLanes(lanes)
+		}
+
+		row := make([]byte, 2*len(lanes))
+		for i := range row {
+			row[i] = ' '
+		}
+		for i, h := range lanes {
+			if h != "" {
+				row[2*i] = '|'
+			}
+		}
+		row[2*col] = '*'
+		lines = append(lines, GraphLine{Snapshot: s, Prefix: strings.TrimRight(string(row), " ")})
+
+		parents := parentsOf(s.Hash)
+		if len(parents) == 0 {
+			lanes[col] = ""
+			lanes = trimLanes(lanes)
+			continue
+		}
+
+		lanes[col] = parents[0]
+		edges := straightEdges(lanes)
+		var forks []int
+		for _, p := range parents[1:] {
+			idx := -1
+			for i, h := range lanes {
+				if h == p {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				idx = freeLane(&lanes)
+				lanes[idx] = p
+			}
+			forks = append(forks, idx)
+		}
+
+		if len(forks) > 0 {
+			// Lanes opened for the extra parents start at the fork itself.
+			for len(edges) < len(lanes) {
+				edges = append(edges, -1)
+			}
+			lines = append(lines, GraphLine{Prefix: renderEdges(edges, map[int][]int{col: forks})})
+		}
+	}
+
+	return lines
+}
+
+// straightEdges maps every active lane to itself and empty lanes to -1.
+func straightEdges(lanes []string) []int {
+	edges := make([]int, len(lanes))
+	for i, h := range lanes {
+		edges[i] = -1
+		if h != "" {
+			edges[i] = i
+		}
+	}
+	return edges
+}
+
+// renderEdges draws a connector line where lane i continues into lane
+// edges[i] and each lane in fan also branches out into its targets.
+func renderEdges(edges []int, fan map[int][]int) string {
+	row := make([]byte, 2*len(edges))
+	for i := range row {
+		row[i] = ' '
+	}
+
+	draw := func(from, to int) {
+		switch {
+		case to > from:
+			for k := 2*from + 1; k < 2*to-1; k++ {
+				row[k] = '-'
+			}
+			row[2*to-1] = '\\'
+		case to < from:
+			row[2*to+1] = '/'
+			for k := 2*to + 2; k < 2*from; k++ {
+				row[k] = '-'
+			}
+			// Across a gap the dashes need the lane itself to start from.
+			if to < from-1 {
+				row[2*from] = '/'
+			}
+		}
+	}
+
+	for from, to := range edges {
+		if to >= 0 && to != from {
+			draw(from, to)
+		}
+	}
+	for from, targets := range fan {
+		for _, to := range targets {
+			if to != from {
+				draw(from, to)
+			}
+		}
+	}
+	for from, to := range edges {
+		if to == from {
+			row[2*from] = '|'
+		}
+	}
+
+	return strings.TrimRight(string(row), " ")
+}
+
+func freeLane(lanes *[]string) int {
+	for i, h := range *lanes {
+		if h == "" {
+			return i
+		}
+	}
+	*lanes = append(*lanes, "")
+	return len(*lanes) - 1
+}
+
+func trimLanes(lanes []string) []string {
+	for len(lanes) > 0 && lanes[len(lanes)-1] == "" {
+		lanes = lanes[:len(lanes)-1]
+	}
+	return lanes
+}
+
+// snapshotHeap pops the newest snapshot first, breaking timestamp ties by
+// hash so the layout is stable.
+type snapshotHeap []*db.Snapshot
+
+func (h snapshotHeap) Len() int { return len(h) }
+func (h snapshotHeap) Less(i, j int) bool {
+	if h[i].Timestamp != h[j].Timestamp {
+		return h[i].Timestamp > h[j].Timestamp
+	}
+	return h[i].Hash < h[j].Hash
+}
+func (h snapshotHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *snapshotHeap) Push(x any)   { *h = append(*h, x.(*db.Snapshot)) }
+func (h *snapshotHeap) Pop() any {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,103 @@
+package ops
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestGraphLanes(t *testing.T) {
+	database := openTestDB(t)
+
+	stamp := func(hash string, ts int64) {
+		t.Helper()
+		if _, err := database.Exec("UPDATE snapshot SET timestamp = ? WHERE hash = ?", ts, hash); err != nil {
+			t.Fatalf("set timestamp: %v", err)
+		}
+	}
+
+	base := commitFiles(t, database, nil, "base", []Change{{Path: "a", ObjectHash: "a1"}})
+	stamp(base, 1000)
+	main := commitFiles(t, database, &base, "main", []Change{{Path: "a", ObjectHash: "a2"}})
+	stamp(main, 1100)
+	side := commitFiles(t, database, &base, "side", []Change{{Path: "b", ObjectHash: "b1"}})
+	stamp(side, 1200)
+	merge, err := CommitTree(database, []string{main, side}, "merge", nil, []db.SnapshotFile{
+		{Path: "a", ObjectHash: "a2"},
+		{Path: "b", ObjectHash: "b1"},
+	})
+	if err != nil {
+		t.Fatalf("commit merge: %v", err)
+	}
+	stamp(merge, 1300)
+	dev := commitFiles(t, database, &side, "dev", []Change{{Path: "c", ObjectHash: "c1"}})
+	stamp(dev, 1400)
+
+	lines, err := Graph(database, []string{merge, dev})
+	if err != nil {
+		t.Fatalf("graph: %v", err)
+	}
+	assertGraph(t, lines, []string{
+		"* dev",
+		"| * merge",
+		"|/|",
+		"* | side",
+		"| * main",
+		"|/",
+		"* base",
+	})
+
+	// A merge whose second parent is not on any lane forks a new one.
+	lines, err = Graph(database, []string{merge})
+	if err != nil {
+		t.Fatalf("graph: %v", err)
+	}
+
+	assertGraph(t, lines, []string{
+		"* merge",
+		"|\\",
+		"| * side",
+		"* | main",
+		"|/",
+		"* base",
+	})
+}
+
+func assertGraph(t *testing.T, lines []GraphLine, want []string) {
+	t.Helper()
+
+	var got []string
+	for _, line := range lines {
+		label := ""
+		if line.Snapshot != nil {
+			label = " " + line.Snapshot.Message
+		}
+		got = append(got, line.Prefix+label)
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("graph:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGraphJoinAcrossGap(t *testing.T) {
+	snapshots := map[string]*db.Snapshot{}
+	for _, h := range []string{"x", "y", "z", "q", "p"} {
+		snapshots[h] = &db.Snapshot{Hash: h, Message: h}
+	}
+	parents := map[string][]string{"x": {"p"}, "y": {"q"}, "z": {"p"}}
+
+	// q ends its lane before p, so z's lane joins p across the empty one.
+	order := []*db.Snapshot{snapshots["x"], snapshots["y"], snapshots["z"], snapshots["q"], snapshots["p"]}
+	lines := layoutGraph(order, func(h string) []string { return parents[h] })
+
+	assertGraph(t, lines, []string{
+		"* x",
+		"| * y",
+		"| | * z",
+		"| * | q",
+		"|/--/",
+		"* p",
+	})
+}
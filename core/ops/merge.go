@@ -0,0 +1,309 @@
+package ops
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/greedypanda0/kuro/core/db"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// MergeConflict is a path both sides changed in incompatible ways.
+// Content holds the text with conflict markers, or nil when the file is
+// binary or one side deleted it.
+type MergeConflict struct {
+	Path    string
+	Content []byte
+}
+
+// MergeResult is the outcome of a three-way tree merge. Conflicted paths
+// keep our version in Files (or theirs when we deleted the file).
+type MergeResult struct {
+	Files     []db.SnapshotFile
+	Conflicts []MergeConflict
+}
+
+// MergeBase returns the best common ancestor of two snapshots, or nil
+// when their histories are unrelated.
+func MergeBase(database db.DBTX, a, b string) (*string, error) {
+	ancestorsA, err := db.ListAncestors(database, a)
+	if err != nil {
+		return nil, err
+	}
+	ancestorsB, err := db.ListAncestors(database, b)
+	if err != nil {
+		return nil, err
+	}
+
+	inA := make(map[string]struct{}, len(ancestorsA))
+	for _, h := range ancestorsA {
+		inA[h] = struct{}{}
+	}
+
+	common := map[string]struct{}{}
+	for _, h := range ancestorsB {
+		if _, ok := inA[h]; ok {
+			common[h] = struct{}{}
+		}
+	}
+	if len(common) == 0 {
+		return nil, nil
+	}
+
+	// Drop every common ancestor that is reachable from another one.
+	for h := range common {
+		ancestors, err := db.ListAncestors(database, h)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range ancestors {
+			if parent != h {
+				delete(common, parent)
+			}
+		}
+	}
+
+	var (
+		best      string
+		bestStamp int64 = -1
+	)
+	for h := range common {
+		snapshot, err := db.GetSnapshot(database, h)
+		if err != nil {
+			return nil, err
+		}
+		if snapshot.Timestamp > bestStamp || (snapshot.Timestamp == bestStamp && h < best) {
+			best = h
+			bestStamp = snapshot.Timestamp
+		}
+	}
+
+	return &best, nil
+}
+
+// MergeTrees performs a file-level three-way merge of ours and theirs
+// against base. Text files changed on both sides are merged line by line;
+// merged content is stored as new objects.
+func MergeTrees(database db.DBTX, base, ours, theirs []db.SnapshotFile, oursLabel, theirsLabel string) (*MergeResult, error) {
+	baseMap := treeMap(base)
+	oursMap := treeMap(ours)
+	theirsMap := treeMap(theirs)
+
+	paths := map[string]struct{}{}
+	for _, m := range []map[string]string{baseMap, oursMap, theirsMap} {
+		for p := range m {
+			paths[p] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	result := &MergeResult{}
+	keep := func(path, hash string) {
+		if hash != "" {
+			result.Files = append(result.Files, db.SnapshotFile{Path: path, ObjectHash: hash})
+		}
+	}
+
+	for _, path := range sorted {
+		b, o, t := baseMap[path], oursMap[path], theirsMap[path]
+
+		switch {
+		case o == t:
+			keep(path, o)
+			continue
+		case b == o:
+			keep(path, t)
+			continue
+		case b == t:
+			keep(path, o)
+			continue
+		}
+
+		if o == "" || t == "" {
+			// Modified on one side, deleted on the other.
+			if o != "" {
+				keep(path, o)
+			} else {
+				keep(path, t)
+			}
+			result.Conflicts = append(result.Conflicts, MergeConflict{Path: path})
+			continue
+		}
+
+		keep(path, o)
+
+		baseContent, err := objectContent(database, b)
+		if err != nil {
+			return nil, err
+		}
+		oursContent, err := objectContent(database, o)
+		if err != nil {
+			return nil, err
+		}
+		theirsContent, err := objectContent(database, t)
+		if err != nil {
+			return nil, err
+		}
+
+		if !IsText(baseContent) || !IsText(oursContent) || !IsText(theirsContent) {
+			result.Conflicts = append(result.Conflicts, MergeConflict{Path: path})
+			continue
+		}
+
+		merged, clean := MergeText(baseContent, oursContent, theirsContent, oursLabel, theirsLabel)
+		if !clean {
+			result.Conflicts = append(result.Conflicts, MergeConflict{Path: path, Content: merged})
+			continue
+		}
+
+		mergedHash, err := StoreObject(database, merged, o)
+		if err != nil {
+			return nil, err
+		}
+		result.Files[len(result.Files)-1].ObjectHash = mergedHash
+	}
+
+	return result, nil
+}
+
+// MergeText merges two line-based edits of base. It reports false when
+// overlapping hunks were written out between conflict markers.
+func MergeText(base, ours, theirs []byte, oursLabel, theirsLabel string) ([]byte, bool) {
+	baseLines := splitLines(base)
+	oursLines := splitLines(ours)
+	theirsLines := splitLines(theirs)
+
+	oursMatch := matchLines(baseLines, oursLines)
+	theirsMatch := matchLines(baseLines, theirsLines)
+
+	var out bytes.Buffer
+	clean := true
+
+	emit := func(lines []string) {
+		for _, line := range lines {
+			out.WriteString(line)
+		}
+	}
+	emitBlock := func(lines []string) {
+		emit(lines)
+		if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	resolve := func(b, o, t []string) {
+		switch {
+		case equalLines(o, t):
+			emit(o)
+		case equalLines(b, o):
+			emit(t)
+		case equalLines(b, t):
+			emit(o)
+		default:
+			clean = false
+			out.WriteString("<<<<<<< " + oursLabel + "\n")
+			emitBlock(o)
+			out.WriteString("=======\n")
+			emitBlock(t)
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+	}
+
+	bi, oi, ti := 0, 0, 0
+	for bi < len(baseLines) {
+		if oursMatch[bi] == oi && theirsMatch[bi] == ti {
+			out.WriteString(baseLines[bi])
+			bi++
+			oi++
+			ti++
+			continue
+		}
+
+		next := bi
+		for next < len(baseLines) && (oursMatch[next] < oi || theirsMatch[next] < ti) {
+			next++
+		}
+		if next == len(baseLines) {
+			break
+		}
+
+		resolve(baseLines[bi:next], oursLines[oi:oursMatch[next]], theirsLines[ti:theirsMatch[next]])
+		bi, oi, ti = next, oursMatch[next], theirsMatch[next]
+	}
+
+	resolve(baseLines[bi:], oursLines[oi:], theirsLines[ti:])
+
+	return out.Bytes(), clean
+}
+
+// IsText reports whether content looks like text rather than binary data.
+func IsText(content []byte) bool {
+	return utf8.Valid(content) && !bytes.Contains(content, []byte{0})
+}
+
+func treeMap(files []db.SnapshotFile) map[string]string {
+	m := make(map[string]string, len(files))
+	for _, f := range files {
+		m[f.Path] = f.ObjectHash
+	}
+	return m
+}
+
+func objectContent(database db.DBTX, hash string) ([]byte, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	obj, err := db.GetObject(database, hash)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Content, nil
+}
+
+// matchLines maps each line of a to its matching line in b, or -1.
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	matcher := difflib.NewMatcher(a, b)
+	for _, block := range matcher.GetMatchingBlocks() {
+		for k := 0; k < block.Size; k++ {
+			match[block.A+k] = block.B + k
+		}
+	}
+
+	return match
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package ops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeTextCombinesDisjointEdits(t *testing.T) {
+	base := []byte("one\ntwo\nthree\nfour\nfive\n")
+	ours := []byte("ONE\ntwo\nthree\nfour\nfive\n")
+	theirs := []byte("one\ntwo\nthree\nfour\nFIVE\n")
+
+	merged, clean := MergeText(base, ours, theirs, "HEAD", "dev")
+	if !clean {
+		t.Fatalf("expected clean merge, got:\n%s", merged)
+	}
+	if string(merged) != "ONE\ntwo\nthree\nfour\nFIVE\n" {
+		t.Fatalf("unexpected merge result:\n%s", merged)
+	}
+}
+
+func TestMergeTextMarksOverlappingEdits(t *testing.T) {
+	base := []byte("a\nb\nc\n")
+	ours := []byte("a\nours\nc\n")
+	theirs := []byte("a\ntheirs\nc\n")
+
+	merged, clean := MergeText(base, ours, theirs, "HEAD", "dev")
+	if clean {
+		t.Fatalf("expected conflict")
+	}
+
+	want := "a\n<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> dev\nc\n"
+	if string(merged) != want {
+		t.Fatalf("unexpected conflict output:\n%s", merged)
+	}
+}
+
+func TestMergeBaseOfDivergedBranches(t *testing.T) {
+	database := openTestDB(t)
+
+	root := commitFiles(t, database, nil, "root", []Change{{Path: "f", ObjectHash: "r"}})
+	left := commitFiles(t, database, &root, "left", []Change{{Path: "l", ObjectHash: "l"}})
+	right := commitFiles(t, database, &root, "right", []Change{{Path: "x", ObjectHash: "x"}})
+
+	base, err := MergeBase(database, left, right)
+	if err != nil {
+		t.Fatalf("merge base: %v", err)
+	}
+	if base == nil || *base != root {
+		t.Fatalf("expected root as merge base, got %v", base)
+	}
+
+	leftFiles, _ := BuildTree(database, &left, nil)
+	rightFiles, _ := BuildTree(database, &right, nil)
+	baseFiles, _ := BuildTree(database, &root, nil)
+
+	result, err := MergeTrees(database, baseFiles, leftFiles, rightFiles, "HEAD", "right")
+	if err != nil {
+		t.Fatalf("merge trees: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", result.Conflicts)
+	}
+
+	var paths []string
+	for _, f := range result.Files {
+		paths = append(paths, f.Path)
+	}
+	if strings.Join(paths, ",") != "f,l,x" {
+		t.Fatalf("unexpected merged tree: %v", paths)
+	}
+}
@@ -0,0 +1,111 @@
+package ops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+// ChunkThreshold is the file size from which content is split into
+// content-defined chunks instead of being stored as a single object.
+const ChunkThreshold = 1 << 20
+
+// StoreObject writes content and returns its hash. When base names an
+// earlier version of the same file the content is stored as a delta
+// against it if that is worthwhile.
+func StoreObject(database db.DBTX, content []byte, base string) (string, error) {
+	hash := Hash(content)
+	if err := db.CreateDeltaObject(database, hash, content, base); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// StoreFile stores the file at path and returns its object hash. Files
+// below ChunkThreshold go through StoreObject, larger ones are streamed
+// into chunks.
+func StoreFile(database db.DBTX, path string, base string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if info.Size() < ChunkThreshold {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return StoreObject(database, content, base)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return StoreChunked(database, f)
+}
+
+// StoreChunked splits r into chunk objects and stores a manifest object
+// keyed by the hash of the full content.
+func StoreChunked(database db.DBTX, r io.Reader) (string, error) {
+	hasher := sha256.New()
+	chunker := NewChunker(r)
+
+	var chunks []db.ChunkRef
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, _ = hasher.Write(chunk)
+
+		chunkHash := Hash(chunk)
+		if err := db.CreateObject(database, chunkHash, chunk); err != nil {
+			return "", err
+		}
+		chunks = append(chunks, db.ChunkRef{Hash: chunkHash, Size: int64(len(chunk))})
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if err := db.CreateChunkedObject(database, hash, chunks); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// HashFile returns the object hash of a file without reading it into
+// memory at once.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// HashObject hashes an object's content as it streams out of the
+// database, so chunked objects are never assembled in memory.
+func HashObject(database db.DBTX, hash string) (string, error) {
+	hasher := sha256.New()
+	if err := db.WriteObject(database, hash, hasher); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
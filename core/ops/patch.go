@@ -0,0 +1,236 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Hunk is a run of changed lines with up to three lines of context. Each
+// line keeps its terminator and is prefixed with ' ' (context), '-'
+// (removed) or '+' (added). OldStart and NewStart are zero-based line
+// indexes of the first line on each side.
+type Hunk struct {
+	OldStart int
+	NewStart int
+	Lines    []string
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of the hunk.
+func (h Hunk) Header() string {
+	var oldLines, newLines int
+	for _, l := range h.Lines {
+		if l[0] != '+' {
+			oldLines++
+		}
+		if l[0] != '-' {
+			newLines++
+		}
+	}
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, oldLines), hunkRange(h.NewStart, newLines))
+}
+
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// String renders the hunk as in a unified diff.
+func (h Hunk) String() string {
+	var b strings.Builder
+	b.WriteString(h.Header())
+	b.WriteByte('\n')
+	for _, l := range h.Lines {
+		b.WriteString(l)
+		if !strings.HasSuffix(l, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return b.String()
+}
+
+// SplitHunks returns the hunks that turn old into new, line endings
+// included.
+func SplitHunks(old, new []byte) []Hunk {
+	a, b := splitLines(old), splitLines(new)
+
+	var hunks []Hunk
+	for _, group := range difflib.NewMatcher(a, b).GetGroupedOpCodes(3) {
+		h := Hunk{OldStart: group[0].I1, NewStart: group[0].J1}
+		for _, op := range group {
+			if op.Tag == 'e' {
+				for _, l := range a[op.I1:op.I2] {
+					h.Lines = append(h.Lines, " "+l)
+				}
+				continue
+			}
+			if op.Tag == 'r' || op.Tag == 'd' {
+				for _, l := range a[op.I1:op.I2] {
+					h.Lines = append(h.Lines, "-"+l)
+				}
+			}
+			if op.Tag == 'r' || op.Tag == 'i' {
+				for _, l := range b[op.J1:op.J2] {
+					h.Lines = append(h.Lines, "+"+l)
+				}
+			}
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// Split breaks a hunk at the context lines between its runs of changes.
+// Context between two runs is shared by both parts. A hunk with a single
+// run is returned as is.
+func (h Hunk) Split() []Hunk {
+	type run struct{ start, end int }
+	var runs []run
+	for i, l := range h.Lines {
+		if l[0] == ' ' {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1].end == i {
+			runs[n-1].end = i + 1
+			continue
+		}
+		runs = append(runs, run{i, i + 1})
+	}
+	if len(runs) < 2 {
+		return []Hunk{h}
+	}
+
+	parts := make([]Hunk, 0, len(runs))
+	for k := range runs {
+		from, to := 0, len(h.Lines)
+		if k > 0 {
+			from = runs[k-1].end
+		}
+		if k < len(runs)-1 {
+			to = runs[k+1].start
+		}
+
+		part := Hunk{OldStart: h.OldStart, NewStart: h.NewStart}
+		for _, l := range h.Lines[:from] {
+			if l[0] != '+' {
+				part.OldStart++
+			}
+			if l[0] != '-' {
+				part.NewStart++
+			}
+		}
+		part.Lines = append([]string(nil), h.Lines[from:to]...)
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// ApplyHunks applies hunks of a diff against old, in order, and returns
+// the result. Hunks that were not selected are simply left out; leading
+// context already emitted by the previous hunk (as after Split) is
+// skipped.
+func ApplyHunks(old []byte, hunks []Hunk) ([]byte, error) {
+	lines := splitLines(old)
+
+	var b strings.Builder
+	cursor := 0
+	for _, h := range hunks {
+		pos, hunkLines := h.OldStart, h.Lines
+		for pos < cursor && len(hunkLines) > 0 && hunkLines[0][0] == ' ' {
+			pos++
+			hunkLines = hunkLines[1:]
+		}
+		if pos < cursor || pos > len(lines) {
+			return nil, fmt.Errorf("hunk %s does not apply", h.Header())
+		}
+
+		for _, l := range lines[cursor:pos] {
+			b.WriteString(l)
+		}
+		for _, l := range hunkLines {
+			if l[0] != '+' {
+				if pos >= len(lines) || lines[pos] != l[1:] {
+					return nil, fmt.Errorf("hunk %s does not apply", h.Header())
+				}
+				pos++
+			}
+			if l[0] != '-' {
+				b.WriteString(l[1:])
+			}
+		}
+		cursor = pos
+	}
+	for _, l := range lines[cursor:] {
+		b.WriteString(l)
+	}
+
+	return []byte(b.String()), nil
+}
+
+const selectHelp = `y - stage this hunk
+n - do not stage this hunk
+s - split this hunk into smaller ones
+q - quit; do not stage this hunk or any remaining ones
+? - print help
+`
+
+// SelectHunks shows each hunk of path on out and asks on in whether to
+// stage it, one answer per line. It returns the accepted hunks in order
+// and whether the user quit; running out of input counts as quitting.
+func SelectHunks(in *bufio.Reader, out io.Writer, path string, hunks []Hunk) ([]Hunk, bool, error) {
+	if _, err := fmt.Fprintf(out, "diff --kuro %s\n--- a/%s\n+++ b/%s\n", path, path, path); err != nil {
+		return nil, false, err
+	}
+
+	var selected []Hunk
+	for len(hunks) > 0 {
+		h := hunks[0]
+		splittable := len(h.Split()) > 1
+
+		options := "y,n,q,?"
+		if splittable {
+			options = "y,n,s,q,?"
+		}
+		if _, err := fmt.Fprintf(out, "%sStage this hunk [%s]? ", h, options); err != nil {
+			return nil, false, err
+		}
+
+		answer, err := in.ReadString('\n')
+		if err == io.EOF && answer == "" {
+			fmt.Fprintln(out)
+			return selected, true, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+
+		switch strings.TrimSpace(answer) {
+		case "y":
+			selected = append(selected, h)
+			hunks = hunks[1:]
+		case "n":
+			hunks = hunks[1:]
+		case "q":
+			return selected, true, nil
+		case "s":
+			if !splittable {
+				fmt.Fprint(out, "Sorry, cannot split this hunk\n")
+				continue
+			}
+			parts := h.Split()
+			fmt.Fprintf(out, "Split into %d hunks.\n", len(parts))
+			hunks = append(parts, hunks[1:]...)
+		default:
+			fmt.Fprint(out, selectHelp)
+		}
+	}
+	return selected, false, nil
+}
@@ -0,0 +1,112 @@
+package ops
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func numberedLines(n int, edit map[int]string) []byte {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := edit[i]; ok {
+			if line != "" {
+				b.WriteString(line + "\n")
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return []byte(b.String())
+}
+
+func TestSplitAndApplyHunks(t *testing.T) {
+	old := numberedLines(20, nil)
+	new := numberedLines(20, map[int]string{2: "changed 2", 15: ""})
+
+	hunks := SplitHunks(old, new)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d: %v", len(hunks), hunks)
+	}
+	if got := hunks[0].Header(); got != "@@ -1,5 +1,5 @@" {
+		t.Fatalf("first header: got %q", got)
+	}
+	if got := hunks[1].Header(); got != "@@ -12,7 +12,6 @@" {
+		t.Fatalf("second header: got %q", got)
+	}
+
+	all, err := ApplyHunks(old, hunks)
+	if err != nil || string(all) != string(new) {
+		t.Fatalf("apply all: got %q, %v", all, err)
+	}
+
+	first, err := ApplyHunks(old, hunks[:1])
+	if err != nil {
+		t.Fatalf("apply first: %v", err)
+	}
+	if want := numberedLines(20, map[int]string{2: "changed 2"}); string(first) != string(want) {
+		t.Fatalf("apply first: got %q", first)
+	}
+
+	none, err := ApplyHunks(old, nil)
+	if err != nil || string(none) != string(old) {
+		t.Fatalf("apply none: got %q, %v", none, err)
+	}
+}
+
+func TestApplyHunksWithoutTrailingNewline(t *testing.T) {
+	old, new := []byte("a\nb"), []byte("a\nc")
+	hunks := SplitHunks(old, new)
+	if !strings.Contains(hunks[0].String(), "\\ No newline at end of file") {
+		t.Fatalf("expected missing newline marker in %q", hunks[0].String())
+	}
+	got, err := ApplyHunks(old, hunks)
+	if err != nil || string(got) != string(new) {
+		t.Fatalf("apply: got %q, %v", got, err)
+	}
+}
+
+func TestSelectHunks(t *testing.T) {
+	old := numberedLines(20, nil)
+	// Changes four lines apart share one hunk that can be split.
+	new := numberedLines(20, map[int]string{5: "changed 5", 9: "changed 9", 18: "changed 18"})
+
+	hunks := SplitHunks(old, new)
+	if len(hunks) != 2 || len(hunks[0].Split()) != 2 {
+		t.Fatalf("unexpected hunks: %v", hunks)
+	}
+
+	tests := []struct {
+		input string
+		quit  bool
+		want  map[int]string
+	}{
+		{input: "y\ny\n", want: map[int]string{5: "changed 5", 9: "changed 9", 18: "changed 18"}},
+		{input: "n\ny\n", want: map[int]string{18: "changed 18"}},
+		{input: "s\nn\ny\nn\n", want: map[int]string{9: "changed 9"}},
+		{input: "s\ny\ny\nq\n", quit: true, want: map[int]string{5: "changed 5", 9: "changed 9"}},
+		{input: "x\ny\n", quit: true, want: map[int]string{5: "changed 5", 9: "changed 9"}},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		selected, quit, err := SelectHunks(bufio.NewReader(strings.NewReader(tt.input)), &out, "f", hunks)
+		if err != nil {
+			t.Fatalf("%q: select: %v", tt.input, err)
+		}
+		if quit != tt.quit {
+			t.Fatalf("%q: quit: got %v, want %v", tt.input, quit, tt.quit)
+		}
+		got, err := ApplyHunks(old, selected)
+		if err != nil {
+			t.Fatalf("%q: apply: %v", tt.input, err)
+		}
+		if want := numberedLines(20, tt.want); string(got) != string(want) {
+			t.Fatalf("%q: got %q, want %q", tt.input, got, want)
+		}
+		if !strings.Contains(out.String(), "Stage this hunk") {
+			t.Fatalf("%q: no prompt in %q", tt.input, out.String())
+		}
+	}
+}
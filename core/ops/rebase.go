@@ -0,0 +1,49 @@
+package ops
+
+import "github.com/greedypanda0/kuro/core/db"
+
+// RebaseTodo returns the snapshots a rebase of tip onto upstream replays,
+// oldest first: every ancestor of tip, through all parents, that upstream
+// does not already contain, with each snapshot after its parents. Merge
+// snapshots are dropped; the snapshots they brought in from the merged
+// branch are replayed in their place, so the result is linear.
+func RebaseTodo(database db.DBTX, tip, upstream string) ([]string, error) {
+	ancestors, err := db.ListAncestors(database, upstream)
+	if err != nil {
+		return nil, err
+	}
+	visited := make(map[string]struct{}, len(ancestors))
+	for _, h := range ancestors {
+		visited[h] = struct{}{}
+	}
+
+	// Depth-first post-order, first parent first, puts every snapshot
+	// after its parents and keeps the first-parent history ahead of the
+	// branches merged into it.
+	var todo []string
+	var walk func(hash string) error
+	walk = func(hash string) error {
+		if _, ok := visited[hash]; ok {
+			return nil
+		}
+		visited[hash] = struct{}{}
+
+		parents, err := db.ListSnapshotParents(database, hash)
+		if err != nil {
+			return err
+		}
+		for _, p := range parents {
+			if err := walk(p); err != nil {
+				return err
+			}
+		}
+		if len(parents) <= 1 {
+			todo = append(todo, hash)
+		}
+		return nil
+	}
+	if err := walk(tip); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
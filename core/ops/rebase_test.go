@@ -0,0 +1,85 @@
+package ops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestRebaseTodoStopsAtUpstream(t *testing.T) {
+	database := openTestDB(t)
+
+	base := commitFiles(t, database, nil, "base", []Change{{Path: "a", ObjectHash: "a1"}})
+	upstream := commitFiles(t, database, &base, "upstream", []Change{{Path: "u", ObjectHash: "u1"}})
+	one := commitFiles(t, database, &base, "one", []Change{{Path: "b", ObjectHash: "b1"}})
+	two := commitFiles(t, database, &one, "two", []Change{{Path: "b", ObjectHash: "b2"}})
+
+	todo, err := RebaseTodo(database, two, upstream)
+	if err != nil {
+		t.Fatalf("rebase todo: %v", err)
+	}
+	if want := []string{one, two}; !reflect.DeepEqual(todo, want) {
+		t.Fatalf("todo: got %v, want %v", todo, want)
+	}
+
+	todo, err = RebaseTodo(database, upstream, two)
+	if err != nil {
+		t.Fatalf("rebase todo: %v", err)
+	}
+	if want := []string{upstream}; !reflect.DeepEqual(todo, want) {
+		t.Fatalf("todo: got %v, want %v", todo, want)
+	}
+
+	todo, err = RebaseTodo(database, one, two)
+	if err != nil {
+		t.Fatalf("rebase todo: %v", err)
+	}
+	if len(todo) != 0 {
+		t.Fatalf("expected nothing to replay, got %v", todo)
+	}
+}
+
+func TestRebaseTodoReplaysMergedBranches(t *testing.T) {
+	database := openTestDB(t)
+
+	base := commitFiles(t, database, nil, "base", []Change{{Path: "a", ObjectHash: "a1"}})
+	upstream := commitFiles(t, database, &base, "upstream", []Change{{Path: "u", ObjectHash: "u1"}})
+	one := commitFiles(t, database, &base, "one", []Change{{Path: "b", ObjectHash: "b1"}})
+	side := commitFiles(t, database, &base, "side", []Change{{Path: "s", ObjectHash: "s1"}})
+	merge, err := CommitTree(database, []string{one, side}, "merge side", nil, []db.SnapshotFile{
+		{Path: "a", ObjectHash: "a1"},
+		{Path: "b", ObjectHash: "b1"},
+		{Path: "s", ObjectHash: "s1"},
+	})
+	if err != nil {
+		t.Fatalf("commit merge: %v", err)
+	}
+	two := commitFiles(t, database, &merge, "two", []Change{{Path: "b", ObjectHash: "b2"}})
+
+	todo, err := RebaseTodo(database, two, upstream)
+	if err != nil {
+		t.Fatalf("rebase todo: %v", err)
+	}
+	if want := []string{one, side, two}; !reflect.DeepEqual(todo, want) {
+		t.Fatalf("todo: got %v, want %v", todo, want)
+	}
+
+	// Snapshots merged in from upstream itself are not replayed.
+	synced, err := CommitTree(database, []string{two, upstream}, "merge upstream", nil, []db.SnapshotFile{
+		{Path: "a", ObjectHash: "a1"},
+		{Path: "b", ObjectHash: "b2"},
+		{Path: "s", ObjectHash: "s1"},
+		{Path: "u", ObjectHash: "u1"},
+	})
+	if err != nil {
+		t.Fatalf("commit merge: %v", err)
+	}
+	todo, err = RebaseTodo(database, synced, upstream)
+	if err != nil {
+		t.Fatalf("rebase todo: %v", err)
+	}
+	if want := []string{one, side, two}; !reflect.DeepEqual(todo, want) {
+		t.Fatalf("todo: got %v, want %v", todo, want)
+	}
+}
@@ -0,0 +1,39 @@
+package ops
+
+import (
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+type RepackReport struct {
+	Objects     int
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+// Repack compresses objects that are still stored raw, for example those
+// written before object compression existed.
+func Repack(database db.DBTX) (*RepackReport, error) {
+	infos, err := db.ListObjectInfo(database)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RepackReport{}
+	for _, info := range infos {
+		if info.Codec != db.CodecRaw {
+			continue
+		}
+
+		before, after, err := db.RecompressObject(database, info.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if after < before {
+			report.Objects++
+			report.BytesBefore += before
+			report.BytesAfter += after
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,106 @@
+package ops
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+type ResetMode int
+
+const (
+	// ResetSoft moves the ref and stages every path that differs between
+	// the old tip and the target, so committing recreates the old tree.
+	ResetSoft ResetMode = iota
+	// ResetMixed moves the ref and clears the stage.
+	ResetMixed
+	// ResetHard is ResetMixed on the database side; callers also reset the
+	// workspace to the target tree.
+	ResetHard
+)
+
+func (m ResetMode) String() string {
+	switch m {
+	case ResetSoft:
+		return "soft"
+	case ResetMixed:
+		return "mixed"
+	case ResetHard:
+		return "hard"
+	default:
+		return fmt.Sprintf("ResetMode(%d)", int(m))
+	}
+}
+
+// Reset moves ref to the target snapshot and rebuilds the stage according
+// to mode. The movement is recorded in the ref log under operation.
+func Reset(database db.DBTX, ref, target string, mode ResetMode, operation string, author *string) error {
+	current, err := db.GetRef(database, ref)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.GetSnapshot(database, target); err != nil {
+		return err
+	}
+
+	if mode == ResetSoft {
+		oldFiles := []db.SnapshotFile{}
+		if current.SnapshotHash != nil {
+			oldFiles, err = db.ListSnapshotFiles(database, *current.SnapshotHash)
+			if err != nil {
+				return err
+			}
+		}
+
+		targetFiles, err := db.ListSnapshotFiles(database, target)
+		if err != nil {
+			return err
+		}
+
+		// The stage keeps the content of the old tip, so committing it
+		// recreates that tip.
+		oldMap := treeMap(oldFiles)
+		for _, path := range changedPaths(oldFiles, targetFiles) {
+			hash, ok := oldMap[path]
+			if !ok {
+				if err := db.StageDeletion(database, path); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := db.AddStageFile(database, path, hash); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := db.ClearStage(database); err != nil {
+			return err
+		}
+	}
+
+	return db.UpdateRef(database, ref, &target, operation, author)
+}
+
+// changedPaths lists the paths added, removed or modified between two
+// trees, sorted.
+func changedPaths(a, b []db.SnapshotFile) []string {
+	am := treeMap(a)
+	bm := treeMap(b)
+
+	var paths []string
+	for path, hash := range am {
+		if bm[path] != hash {
+			paths = append(paths, path)
+		}
+	}
+	for path := range bm {
+		if _, ok := am[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
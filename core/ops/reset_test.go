@@ -0,0 +1,130 @@
+package ops
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func setupResetHistory(t *testing.T) (db.DBTX, string, string) {
+	t.Helper()
+	database := openTestDB(t)
+
+	first := commitFiles(t, database, nil, "first", []Change{
+		{Path: "a", ObjectHash: "a1"},
+		{Path: "b", ObjectHash: "b1"},
+	})
+	second := commitFiles(t, database, &first, "second", []Change{
+		{Path: "a", ObjectHash: "a2"},
+		{Path: "b", Deleted: true},
+		{Path: "c", ObjectHash: "c1"},
+	})
+	if err := db.UpdateRef(database, "main", &second, "commit: second", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+	if err := db.AddStageFile(database, "pending", "p1"); err != nil {
+		t.Fatalf("stage: %v", err)
+	}
+
+	return database, first, second
+}
+
+func stagedSet(t *testing.T, database db.DBTX) []string {
+	t.Helper()
+	stage, err := db.GetStageFiles(database)
+	if err != nil {
+		t.Fatalf("get stage: %v", err)
+	}
+	paths := []string{}
+	for _, s := range stage {
+		paths = append(paths, s.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func assertRef(t *testing.T, database db.DBTX, want string) {
+	t.Helper()
+	ref, err := db.GetRef(database, "main")
+	if err != nil {
+		t.Fatalf("get ref: %v", err)
+	}
+	if ref.SnapshotHash == nil || *ref.SnapshotHash != want {
+		t.Fatalf("expected main at %s, got %v", want, ref.SnapshotHash)
+	}
+
+	entries, err := db.ListRefLog(database, "main")
+	if err != nil {
+		t.Fatalf("list ref log: %v", err)
+	}
+	if len(entries) == 0 || entries[0].Operation != "reset: moving to first" {
+		t.Fatalf("expected reset to be logged, got %+v", entries)
+	}
+}
+
+func TestResetSoftStagesDifferences(t *testing.T) {
+	database, first, _ := setupResetHistory(t)
+
+	if err := Reset(database, "main", first, ResetSoft, "reset: moving to first", nil); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	assertRef(t, database, first)
+	if got, want := stagedSet(t, database), []string{"a", "b", "c", "pending"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("staged: got %v, want %v", got, want)
+	}
+	stage, err := db.GetStageFiles(database)
+	if err != nil {
+		t.Fatalf("get stage: %v", err)
+	}
+	hashes := map[string]string{}
+	for _, s := range stage {
+		hashes[s.Path] = s.ObjectHash
+		if s.Deleted != (s.Path == "b") {
+			t.Fatalf("only b should be staged as deleted, got %+v", s)
+		}
+	}
+	if want := map[string]string{"a": "a2", "b": "", "c": "c1", "pending": "p1"}; !reflect.DeepEqual(hashes, want) {
+		t.Fatalf("staged content: got %v, want %v", hashes, want)
+	}
+}
+
+func TestResetMixedClearsStage(t *testing.T) {
+	database, first, _ := setupResetHistory(t)
+
+	if err := Reset(database, "main", first, ResetMixed, "reset: moving to first", nil); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	assertRef(t, database, first)
+	if got := stagedSet(t, database); len(got) != 0 {
+		t.Fatalf("expected empty stage, got %v", got)
+	}
+}
+
+func TestResetHardClearsStage(t *testing.T) {
+	database, first, second := setupResetHistory(t)
+
+	if err := Reset(database, "main", first, ResetHard, "reset: moving to first", nil); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+
+	assertRef(t, database, first)
+	if got := stagedSet(t, database); len(got) != 0 {
+		t.Fatalf("expected empty stage, got %v", got)
+	}
+
+	if err := Reset(database, "main", "missing", ResetHard, "reset: moving to missing", nil); err == nil {
+		t.Fatalf("expected reset to an unknown snapshot to fail")
+	}
+
+	entries, err := db.ListRefLog(database, "main")
+	if err != nil {
+		t.Fatalf("list ref log: %v", err)
+	}
+	if entries[0].OldHash == nil || *entries[0].OldHash != second {
+		t.Fatalf("expected the abandoned tip in the ref log, got %+v", entries[0])
+	}
+}
@@ -0,0 +1,133 @@
+package ops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// minHashPrefix is the shortest abbreviated snapshot hash accepted.
+const minHashPrefix = 4
+
+// OrigHeadKey is the config key holding the branch tip from before the
+// last rebase, resolvable as ORIG_HEAD.
+const OrigHeadKey = "orig_head"
+
+// ResolveRev resolves a revision to a snapshot hash. A revision is HEAD,
+// ORIG_HEAD, a branch, a tag, or a full or abbreviated snapshot hash, optionally
+// followed by ~N (Nth first-parent ancestor) and ^N (Nth parent) suffixes.
+func ResolveRev(database db.DBTX, rev string) (string, error) {
+	name, suffix := rev, ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		name, suffix = rev[:i], rev[i:]
+	}
+	if name == "" {
+		return "", fmt.Errorf("%w: %s", errors.ErrRevisionNotFound, rev)
+	}
+
+	hash, err := resolveName(database, name)
+	if err != nil {
+		return "", err
+	}
+
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+		n := 1
+		if digits > 0 {
+			n, _ = strconv.Atoi(suffix[:digits])
+			suffix = suffix[digits:]
+		}
+
+		switch op {
+		case '~':
+			for i := 0; i < n; i++ {
+				hash, err = nthParent(database, hash, 1, rev)
+				if err != nil {
+					return "", err
+				}
+			}
+		case '^':
+			if n == 0 {
+				continue
+			}
+			hash, err = nthParent(database, hash, n, rev)
+			if err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("%w: %s", errors.ErrRevisionNotFound, rev)
+		}
+	}
+
+	return hash, nil
+}
+
+func resolveName(database db.DBTX, name string) (string, error) {
+	if name == "ORIG_HEAD" {
+		hash, err := db.GetConfig(database, OrigHeadKey)
+		if err == errors.ErrDataNotFound {
+			return "", fmt.Errorf("%w: %s", errors.ErrRevisionNotFound, name)
+		}
+		return hash, err
+	}
+	if name == "HEAD" {
+		head, err := db.GetConfig(database, "head")
+		if err != nil {
+			return "", err
+		}
+		name = head
+	}
+
+	ref, err := db.GetRef(database, name)
+	if err == nil {
+		if ref.SnapshotHash == nil {
+			return "", fmt.Errorf("%w: %s has no commits", errors.ErrRevisionNotFound, name)
+		}
+		return *ref.SnapshotHash, nil
+	}
+	if err != errors.ErrRefNotFound {
+		return "", err
+	}
+
+	tag, err := db.GetTag(database, name)
+	if err == nil {
+		return tag.SnapshotHash, nil
+	}
+	if err != errors.ErrTagNotFound {
+		return "", err
+	}
+
+	if len(name) < minHashPrefix || strings.Trim(name, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("%w: %s", errors.ErrRevisionNotFound, name)
+	}
+
+	hashes, err := db.FindSnapshotsByPrefix(database, name)
+	if err != nil {
+		return "", err
+	}
+	switch len(hashes) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", errors.ErrRevisionNotFound, name)
+	case 1:
+		return hashes[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s matches %d snapshots", errors.ErrAmbiguousRevision, name, len(hashes))
+	}
+}
+
+func nthParent(database db.DBTX, hash string, n int, rev string) (string, error) {
+	parents, err := db.ListSnapshotParents(database, hash)
+	if err != nil {
+		return "", err
+	}
+	if n > len(parents) {
+		return "", fmt.Errorf("%w: %s", errors.ErrRevisionNotFound, rev)
+	}
+	return parents[n-1], nil
+}
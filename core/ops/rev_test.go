@@ -0,0 +1,69 @@
+package ops
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+func TestResolveRev(t *testing.T) {
+	database := openTestDB(t)
+
+	first := commitFiles(t, database, nil, "first", []Change{{Path: "a", ObjectHash: "a1"}})
+	second := commitFiles(t, database, &first, "second", []Change{{Path: "a", ObjectHash: "a2"}})
+	side := commitFiles(t, database, &first, "side", []Change{{Path: "b", ObjectHash: "b1"}})
+
+	merge, err := CommitTree(database, []string{second, side}, "merge", nil, nil)
+	if err != nil {
+		t.Fatalf("commit merge: %v", err)
+	}
+
+	if err := db.UpdateRef(database, "main", &merge, "commit", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+	if err := db.SetConfig(database, "head", "main"); err != nil {
+		t.Fatalf("set head: %v", err)
+	}
+	message := "release"
+	if err := db.CreateTag(database, "v1", second, &message, nil); err != nil {
+		t.Fatalf("create tag: %v", err)
+	}
+
+	cases := map[string]string{
+		"HEAD":        merge,
+		"main":        merge,
+		"main~1":      second,
+		"HEAD~2":      first,
+		"HEAD^2":      side,
+		"HEAD^2~1":    first,
+		"v1":          second,
+		"v1^":         first,
+		merge:         merge,
+		side[:12]:     side,
+		second + "^0": second,
+	}
+	for rev, want := range cases {
+		got, err := ResolveRev(database, rev)
+		if err != nil {
+			t.Fatalf("resolve %s: %v", rev, err)
+		}
+		if got != want {
+			t.Fatalf("resolve %s: got %s, want %s", rev, got, want)
+		}
+	}
+
+	for _, rev := range []string{"missing", "HEAD~5", "HEAD^3", "zz", "ORIG_HEAD"} {
+		if _, err := ResolveRev(database, rev); !stderrors.Is(err, errors.ErrRevisionNotFound) {
+			t.Fatalf("resolve %s: expected revision not found, got %v", rev, err)
+		}
+	}
+
+	if err := db.SetConfig(database, OrigHeadKey, side); err != nil {
+		t.Fatalf("set orig head: %v", err)
+	}
+	if got, err := ResolveRev(database, "ORIG_HEAD~1"); err != nil || got != first {
+		t.Fatalf("resolve ORIG_HEAD~1: got %s, %v, want %s", got, err, first)
+	}
+}
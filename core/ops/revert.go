@@ -0,0 +1,54 @@
+package ops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+// RevertTree undoes the changes a snapshot made to its first parent on top
+// of the given tree. It is a three-way merge with the snapshot as base,
+// so conflicts are reported where later snapshots touched the same lines.
+func RevertTree(database db.DBTX, headFiles []db.SnapshotFile, target string) (*MergeResult, error) {
+	targetFiles, parentFiles, err := snapshotChange(database, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return MergeTrees(database, targetFiles, headFiles, parentFiles, "HEAD", "parent of "+target)
+}
+
+// RevertMessage is the default message for a snapshot reverting s.
+func RevertMessage(s *db.Snapshot) string {
+	return fmt.Sprintf("Revert %q\n\nThis reverts snapshot %s.", firstLine(s.Message), s.Hash)
+}
+
+// snapshotChange returns the trees of a snapshot and of its first parent
+// (empty for a root snapshot).
+func snapshotChange(database db.DBTX, hash string) ([]db.SnapshotFile, []db.SnapshotFile, error) {
+	files, err := db.ListSnapshotFiles(database, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parents, err := db.ListSnapshotParents(database, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parentFiles := []db.SnapshotFile{}
+	if len(parents) > 0 {
+		parentFiles, err = db.ListSnapshotFiles(database, parents[0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return files, parentFiles, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,59 @@
+package ops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestRevertTreeUndoesSnapshot(t *testing.T) {
+	database := openTestDB(t)
+
+	first := commitFiles(t, database, nil, "first", []Change{{Path: "a", ObjectHash: "a1"}})
+	second := commitFiles(t, database, &first, "second", []Change{
+		{Path: "a", ObjectHash: "a2"},
+		{Path: "b", ObjectHash: "b1"},
+	})
+	third := commitFiles(t, database, &second, "third", []Change{{Path: "c", ObjectHash: "c1"}})
+
+	headFiles, err := db.ListSnapshotFiles(database, third)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+
+	result, err := RevertTree(database, headFiles, second)
+	if err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %+v", result.Conflicts)
+	}
+
+	got := treeMap(result.Files)
+	want := map[string]string{"a": "a1", "c": "c1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("reverted tree: got %v, want %v", got, want)
+	}
+}
+
+func TestRevertTreeReportsLaterEdits(t *testing.T) {
+	database := openTestDB(t)
+
+	first := commitFiles(t, database, nil, "first", []Change{{Path: "a", ObjectHash: "a1"}})
+	second := commitFiles(t, database, &first, "second", []Change{{Path: "a", ObjectHash: "a2"}})
+	third := commitFiles(t, database, &second, "third", []Change{{Path: "a", ObjectHash: "a3"}})
+
+	headFiles, err := db.ListSnapshotFiles(database, third)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+
+	result, err := RevertTree(database, headFiles, second)
+	if err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Path != "a" {
+		t.Fatalf("expected conflict on a, got %+v", result.Conflicts)
+	}
+}
@@ -0,0 +1,196 @@
+package ops
+
+import "github.com/greedypanda0/kuro/core/db"
+
+// HeadAndStage returns the tree of the snapshot HEAD points to (empty on
+// an unborn branch) and the stage keyed by path.
+func HeadAndStage(database db.DBTX) ([]db.SnapshotFile, map[string]db.Stage, error) {
+	head, err := db.GetConfig(database, "head")
+	if err != nil {
+		return nil, nil, err
+	}
+	ref, err := db.GetRef(database, head)
+	if err != nil {
+		return nil, nil, err
+	}
+	headFiles := []db.SnapshotFile{}
+	if ref.SnapshotHash != nil {
+		if headFiles, err = db.ListSnapshotFiles(database, *ref.SnapshotHash); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	stageFiles, err := db.GetStageFiles(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	staged := make(map[string]db.Stage, len(stageFiles))
+	for _, f := range stageFiles {
+		staged[f.Path] = f
+	}
+
+	return headFiles, staged, nil
+}
+
+// StageTree overlays the staged content and deletions on the HEAD tree,
+// which is what the next commit will record. Paths staged without content
+// are read from the workspace and left out when missing there.
+func StageTree(cache *StatCache, headFiles []db.SnapshotFile, staged map[string]db.Stage) ([]db.SnapshotFile, error) {
+	files := make([]db.SnapshotFile, 0, len(headFiles)+len(staged))
+	var paths []string
+	for path, f := range staged {
+		switch {
+		case f.Deleted:
+		case f.ObjectHash == "":
+			paths = append(paths, path)
+		default:
+			files = append(files, db.SnapshotFile{Path: path, ObjectHash: f.ObjectHash})
+		}
+	}
+	workspaceFiles, err := cache.HashWorkspace(paths)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, workspaceFiles...)
+
+	for _, f := range headFiles {
+		if _, ok := staged[f.Path]; !ok {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// StageSource reads paths staged without content from the workspace and
+// everything else from the object store.
+func StageSource(objects, workspace ContentSource, staged map[string]db.Stage) ContentSource {
+	return func(path, hash string) ([]byte, error) {
+		if f, ok := staged[path]; ok && !f.Deleted && f.ObjectHash == "" {
+			return workspace(path, hash)
+		}
+		return objects(path, hash)
+	}
+}
+
+// TreeDiff holds the changes between two trees with what rename detection
+// and rendering need: the old tree and the content source of each side.
+type TreeDiff struct {
+	Changes []FileChange
+	OldTree []db.SnapshotFile
+	Old     ContentSource
+	New     ContentSource
+}
+
+// DiffSnapshots compares the trees of two snapshots.
+func DiffSnapshots(database db.DBTX, from, to string) (*TreeDiff, error) {
+	fromFiles, err := db.ListSnapshotFiles(database, from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := db.ListSnapshotFiles(database, to)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := ObjectSource(database)
+	return &TreeDiff{Changes: DiffTrees(fromFiles, toFiles), OldTree: fromFiles, Old: objects, New: objects}, nil
+}
+
+// DiffStaged compares the snapshot base, or HEAD when base is nil, with
+// the stage.
+func DiffStaged(database db.DBTX, cache *StatCache, base *string) (*TreeDiff, error) {
+	headFiles, staged, err := HeadAndStage(database)
+	if err != nil {
+		return nil, err
+	}
+	baseFiles := headFiles
+	if base != nil {
+		if baseFiles, err = db.ListSnapshotFiles(database, *base); err != nil {
+			return nil, err
+		}
+	}
+	stageFiles, err := StageTree(cache, headFiles, staged)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := ObjectSource(database)
+	return &TreeDiff{
+		Changes: DiffTrees(baseFiles, stageFiles),
+		OldTree: baseFiles,
+		Old:     objects,
+		New:     StageSource(objects, WorkspaceSource(cache.root), staged),
+	}, nil
+}
+
+// DiffWorkspace compares the snapshot base with the workspace. The
+// workspace side holds the files tracked in base, HEAD or the stage, so
+// untracked files are left out.
+func DiffWorkspace(database db.DBTX, cache *StatCache, base string) (*TreeDiff, error) {
+	baseFiles, err := db.ListSnapshotFiles(database, base)
+	if err != nil {
+		return nil, err
+	}
+	headFiles, staged, err := HeadAndStage(database)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracked []string
+	for _, files := range [][]db.SnapshotFile{baseFiles, headFiles} {
+		for _, f := range files {
+			tracked = append(tracked, f.Path)
+		}
+	}
+	for path := range staged {
+		tracked = append(tracked, path)
+	}
+	workspaceFiles, err := cache.HashWorkspace(tracked)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TreeDiff{
+		Changes: DiffTrees(baseFiles, workspaceFiles),
+		OldTree: baseFiles,
+		Old:     ObjectSource(database),
+		New:     WorkspaceSource(cache.root),
+	}, nil
+}
+
+// DiffUnstaged compares the stage with the workspace. Paths staged without
+// content take it from the workspace, so they are left out.
+func DiffUnstaged(database db.DBTX, cache *StatCache) (*TreeDiff, error) {
+	headFiles, staged, err := HeadAndStage(database)
+	if err != nil {
+		return nil, err
+	}
+	stageFiles, err := StageTree(cache, headFiles, staged)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		unstaged []db.SnapshotFile
+		tracked  []string
+	)
+	for _, f := range stageFiles {
+		if s, ok := staged[f.Path]; ok && s.ObjectHash == "" {
+			continue
+		}
+		unstaged = append(unstaged, f)
+		tracked = append(tracked, f.Path)
+	}
+	workspaceFiles, err := cache.HashWorkspace(tracked)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := WorkspaceSource(cache.root)
+	return &TreeDiff{
+		Changes: DiffTrees(unstaged, workspaceFiles),
+		OldTree: stageFiles,
+		Old:     StageSource(ObjectSource(database), workspace, staged),
+		New:     workspace,
+	}, nil
+}
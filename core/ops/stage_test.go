@@ -0,0 +1,64 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestDiffStagedUnstagedAndWorkspace(t *testing.T) {
+	database := openTestDB(t)
+	root := t.TempDir()
+
+	hash := func(content string) string { return Hash([]byte(content)) }
+	head := commitFiles(t, database, nil, "base", []Change{
+		{Path: "a", ObjectHash: hash("a1\n")},
+		{Path: "b", ObjectHash: hash("b1\n")},
+	})
+	if err := db.UpdateRef(database, "main", &head, "commit", nil); err != nil {
+		t.Fatalf("update ref: %v", err)
+	}
+	if err := db.SetConfig(database, "head", "main"); err != nil {
+		t.Fatalf("set head: %v", err)
+	}
+
+	// a is staged as a2 and edited again to a3; c is untracked.
+	if err := db.AddStageFile(database, "a", hash("a2\n")); err != nil {
+		t.Fatalf("stage a: %v", err)
+	}
+	for path, content := range map[string]string{"a": "a3\n", "b": "b1\n", "c": "c\n"} {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	cache, err := LoadStatCache(database, root)
+	if err != nil {
+		t.Fatalf("load stat cache: %v", err)
+	}
+
+	modified := func(old, new string) []FileChange {
+		return []FileChange{{Path: "a", Status: StatusModified, OldHash: hash(old), NewHash: hash(new)}}
+	}
+	tests := []struct {
+		name string
+		diff func() (*TreeDiff, error)
+		want []FileChange
+	}{
+		{"staged", func() (*TreeDiff, error) { return DiffStaged(database, cache, nil) }, modified("a1\n", "a2\n")},
+		{"unstaged", func() (*TreeDiff, error) { return DiffUnstaged(database, cache) }, modified("a2\n", "a3\n")},
+		{"workspace", func() (*TreeDiff, error) { return DiffWorkspace(database, cache, head) }, modified("a1\n", "a3\n")},
+	}
+	for _, tt := range tests {
+		d, err := tt.diff()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(d.Changes, tt.want) {
+			t.Fatalf("%s: got %+v, want %+v", tt.name, d.Changes, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ops
+
+import "github.com/greedypanda0/kuro/core/db"
+
+// RestoreStashedStage stages the rows saved with a stash once its tree
+// (stashFiles) has been merged into the workspace as result. Rows come
+// back as they were saved, so content staged before later edits stays
+// staged; a path the merge changed stages the merged content instead, and
+// conflicted paths are left for the user to add.
+func RestoreStashedStage(database db.DBTX, stashID int64, stashFiles []db.SnapshotFile, result *MergeResult) error {
+	staged, err := db.ListStashStagedFiles(database, stashID)
+	if err != nil {
+		return err
+	}
+
+	stashed := make(map[string]string, len(stashFiles))
+	for _, f := range stashFiles {
+		stashed[f.Path] = f.ObjectHash
+	}
+	merged := make(map[string]string, len(result.Files))
+	for _, f := range result.Files {
+		merged[f.Path] = f.ObjectHash
+	}
+	conflicted := make(map[string]struct{}, len(result.Conflicts))
+	for _, c := range result.Conflicts {
+		conflicted[c.Path] = struct{}{}
+	}
+
+	for _, f := range staged {
+		if _, ok := conflicted[f.Path]; ok {
+			continue
+		}
+
+		hash, ok := merged[f.Path]
+		switch {
+		case f.Deleted || !ok:
+			err = db.StageDeletion(database, f.Path)
+		case hash == stashed[f.Path]:
+			err = db.AddStageFile(database, f.Path, f.ObjectHash)
+		default:
+			err = db.AddStageFile(database, f.Path, hash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ops
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestStashRoundTripKeepsStagedContent(t *testing.T) {
+	database := openTestDB(t)
+
+	head := commitFiles(t, database, nil, "base", []Change{
+		{Path: "f", ObjectHash: "f1"},
+		{Path: "g", ObjectHash: "g1"},
+	})
+	for _, hash := range []string{"f2", "f3"} {
+		if err := db.CreateObject(database, hash, []byte(hash)); err != nil {
+			t.Fatalf("create object: %v", err)
+		}
+	}
+
+	// f is staged as f2 then edited again to f3; g's deletion is staged
+	// while the file stays in the workspace.
+	if err := db.AddStageFile(database, "f", "f2"); err != nil {
+		t.Fatalf("stage f: %v", err)
+	}
+	if err := db.StageDeletion(database, "g"); err != nil {
+		t.Fatalf("stage deletion of g: %v", err)
+	}
+	staged, err := db.GetStageFiles(database)
+	if err != nil {
+		t.Fatalf("get stage: %v", err)
+	}
+
+	stash, err := CommitTree(database, []string{head}, "stash: wip", nil, []db.SnapshotFile{
+		{Path: "f", ObjectHash: "f3"},
+		{Path: "g", ObjectHash: "g1"},
+	})
+	if err != nil {
+		t.Fatalf("commit stash tree: %v", err)
+	}
+	id, err := db.CreateStash(database, stash, &head, "main", "wip", staged)
+	if err != nil {
+		t.Fatalf("create stash: %v", err)
+	}
+	if err := db.ClearStage(database); err != nil {
+		t.Fatalf("clear stage: %v", err)
+	}
+
+	report, err := CollectGarbage(database, GCOptions{DryRun: true, Cutoff: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	if len(report.Objects) != 0 {
+		t.Fatalf("expected stashed staged content to survive, got %+v", report)
+	}
+
+	headFiles, err := db.ListSnapshotFiles(database, head)
+	if err != nil {
+		t.Fatalf("list head files: %v", err)
+	}
+	stashFiles, err := db.ListSnapshotFiles(database, stash)
+	if err != nil {
+		t.Fatalf("list stash files: %v", err)
+	}
+	result, err := MergeTrees(database, headFiles, headFiles, stashFiles, "Updated upstream", "Stashed changes")
+	if err != nil {
+		t.Fatalf("merge stash: %v", err)
+	}
+	if err := RestoreStashedStage(database, id, stashFiles, result); err != nil {
+		t.Fatalf("restore stage: %v", err)
+	}
+
+	restored, err := db.GetStageFiles(database)
+	if err != nil {
+		t.Fatalf("get stage: %v", err)
+	}
+	type row struct {
+		hash    string
+		deleted bool
+	}
+	got := map[string]row{}
+	for _, f := range restored {
+		got[f.Path] = row{f.ObjectHash, f.Deleted}
+	}
+	want := map[string]row{"f": {"f2", false}, "g": {"", true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("restored stage: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package ops
+
+import "os"
+
+// fileInode returns 0: inode numbers are not available on this platform,
+// so the stat cache relies on size and modification time alone.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
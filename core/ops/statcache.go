@@ -0,0 +1,142 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+// racyWindow is how long before it was indexed a file must have last been
+// modified for its cached hash to be trusted. A write in the same
+// timestamp tick as the hashing leaves the stat data unchanged, so a
+// recently modified file is rehashed until it is older than the coarsest
+// (2 second) filesystem timestamp resolution.
+const racyWindow = 2 * time.Second
+
+// StatCache hashes workspace files through the file_index table, only
+// reading files whose size, modification time or inode changed since they
+// were last hashed. Changes are kept in memory until Save.
+type StatCache struct {
+	root    string
+	entries map[string]db.IndexEntry
+	dirty   map[string]bool
+}
+
+// LoadStatCache reads the stat cache of the workspace at root.
+func LoadStatCache(database db.DBTX, root string) (*StatCache, error) {
+	entries, err := db.ListIndexEntries(database)
+	if err != nil {
+		return nil, err
+	}
+	return &StatCache{root: root, entries: entries, dirty: map[string]bool{}}, nil
+}
+
+// Hash returns the object hash of the workspace file at path, reusing the
+// cached hash when the file is unchanged.
+func (c *StatCache) Hash(path string) (string, error) {
+	absPath := filepath.Join(c.root, filepath.FromSlash(path))
+	info, err := c.stat(path, absPath)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := c.lookup(path, info); ok {
+		return hash, nil
+	}
+
+	hash, err := HashFile(absPath)
+	if err != nil {
+		return "", err
+	}
+	c.record(path, info, hash)
+	return hash, nil
+}
+
+// HashWorkspace is like the package-level HashWorkspace, going through the
+// cache.
+func (c *StatCache) HashWorkspace(paths []string) ([]db.SnapshotFile, error) {
+	return hashPaths(paths, c.Hash)
+}
+
+// Store stores the workspace file at path like StoreFile, without reading
+// it when the cached hash is valid and its object is already stored.
+func (c *StatCache) Store(database db.DBTX, path, base string) (string, error) {
+	absPath := filepath.Join(c.root, filepath.FromSlash(path))
+	info, err := c.stat(path, absPath)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := c.lookup(path, info); ok {
+		stored, err := db.HasObject(database, hash)
+		if err != nil {
+			return "", err
+		}
+		if stored {
+			return hash, nil
+		}
+	}
+
+	hash, err := StoreFile(database, absPath, base)
+	if err != nil {
+		return "", err
+	}
+	c.record(path, info, hash)
+	return hash, nil
+}
+
+// Save writes the entries changed since the cache was loaded.
+func (c *StatCache) Save(database db.DBTX) error {
+	for path := range c.dirty {
+		e, ok := c.entries[path]
+		if !ok {
+			if err := db.DeleteIndexEntry(database, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := db.PutIndexEntry(database, e); err != nil {
+			return err
+		}
+	}
+	c.dirty = map[string]bool{}
+	return nil
+}
+
+// stat stats a workspace file, dropping the entry of a missing one.
+func (c *StatCache) stat(path, absPath string) (os.FileInfo, error) {
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		if _, ok := c.entries[path]; ok {
+			delete(c.entries, path)
+			c.dirty[path] = true
+		}
+	}
+	return info, err
+}
+
+func (c *StatCache) lookup(path string, info os.FileInfo) (string, bool) {
+	e, ok := c.entries[path]
+	if !ok {
+		return "", false
+	}
+	if e.Size != info.Size() || e.MTime != info.ModTime().UnixNano() || e.Inode != fileInode(info) {
+		return "", false
+	}
+	if e.IndexedAt-e.MTime < int64(racyWindow) {
+		return "", false
+	}
+	return e.ObjectHash, true
+}
+
+func (c *StatCache) record(path string, info os.FileInfo, hash string) {
+	c.entries[path] = db.IndexEntry{
+		Path:       path,
+		Size:       info.Size(),
+		MTime:      info.ModTime().UnixNano(),
+		Inode:      fileInode(info),
+		ObjectHash: hash,
+		IndexedAt:  time.Now().UnixNano(),
+	}
+	c.dirty[path] = true
+}
@@ -0,0 +1,99 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestStatCache(t *testing.T) {
+	database := openTestDB(t)
+	root := t.TempDir()
+	abs := filepath.Join(root, "f.txt")
+
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := os.Chtimes(abs, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		cache, err := LoadStatCache(database, root)
+		if err != nil {
+			t.Fatalf("load stat cache: %v", err)
+		}
+		h, err := cache.Hash("f.txt")
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		if err := cache.Save(database); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		return h
+	}
+
+	// A file written in the same tick it was indexed is racily clean: a
+	// same-size rewrite keeps its stat data, so it must not be trusted.
+	now := time.Now()
+	write("one\n", now)
+	if got := hash(); got != Hash([]byte("one\n")) {
+		t.Fatalf("first hash: got %s", got)
+	}
+	write("two\n", now)
+	if got := hash(); got != Hash([]byte("two\n")) {
+		t.Fatalf("racy entry was trusted: got %s", got)
+	}
+
+	// Once the file is older than the racy window its stat data is enough,
+	// which a rewrite that restores it exposes.
+	old := now.Add(-time.Hour)
+	write("old\n", old)
+	if got := hash(); got != Hash([]byte("old\n")) {
+		t.Fatalf("old hash: got %s", got)
+	}
+	write("new\n", old)
+	if got := hash(); got != Hash([]byte("old\n")) {
+		t.Fatalf("expected cached hash, got %s", got)
+	}
+	write("new\n", old.Add(time.Second))
+	if got := hash(); got != Hash([]byte("new\n")) {
+		t.Fatalf("changed mtime was not rehashed: got %s", got)
+	}
+
+	// Store skips the read only while the object is stored.
+	cache, err := LoadStatCache(database, root)
+	if err != nil {
+		t.Fatalf("load stat cache: %v", err)
+	}
+	stored, err := cache.Store(database, "f.txt", "")
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if ok, err := db.HasObject(database, stored); err != nil || !ok {
+		t.Fatalf("object not stored: %v", err)
+	}
+
+	if err := os.Remove(abs); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := cache.Hash("f.txt"); !os.IsNotExist(err) {
+		t.Fatalf("expected missing file, got %v", err)
+	}
+	if err := cache.Save(database); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	entries, err := db.ListIndexEntries(database)
+	if err != nil {
+		t.Fatalf("list index entries: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected entry of missing file to be dropped, got %+v", entries)
+	}
+}
@@ -0,0 +1,79 @@
+package ops
+
+import "sort"
+
+// StatusUntracked marks a workspace file that is neither in HEAD nor
+// staged.
+const StatusUntracked ChangeStatus = '?'
+
+// FileStatus is the state of a path across HEAD, the stage and the
+// workspace. Staged compares HEAD with the stage and Unstaged compares the
+// stage with the workspace; zero means unchanged on that side. Untracked
+// files have both set to StatusUntracked. OldPath is the source of a
+// rename or copy.
+type FileStatus struct {
+	Path     string
+	OldPath  string
+	Staged   ChangeStatus
+	Unstaged ChangeStatus
+}
+
+// Code returns the two-letter XY code of the path, with a space for an
+// unchanged side.
+func (s FileStatus) Code() string {
+	code := []byte{' ', ' '}
+	if s.Staged != 0 {
+		code[0] = byte(s.Staged)
+	}
+	if s.Unstaged != 0 {
+		code[1] = byte(s.Unstaged)
+	}
+	return string(code)
+}
+
+// WorkingStatus combines the changes from HEAD to the stage with the
+// changes from the stage to the workspace into one entry per changed path,
+// sorted by path. Files added in the workspace are untracked, and get an
+// entry of their own when their deletion is staged; paths that appear in
+// neither list are unchanged. The destination of a rename or copy in the
+// workspace is untracked too, so it is reported as such, with a renamed
+// source shown as deleted.
+func WorkingStatus(staged, unstaged []FileChange) []FileStatus {
+	entries := map[string]*FileStatus{}
+	entry := func(path string) *FileStatus {
+		if e, ok := entries[path]; ok {
+			return e
+		}
+		e := &FileStatus{Path: path}
+		entries[path] = e
+		return e
+	}
+
+	for _, c := range staged {
+		e := entry(c.Path)
+		e.Staged = c.Status
+		e.OldPath = c.OldPath
+	}
+	var untracked []FileStatus
+	for _, c := range unstaged {
+		switch c.Status {
+		case StatusAdded, StatusRenamed, StatusCopied:
+			untracked = append(untracked, FileStatus{Path: c.Path, Staged: StatusUntracked, Unstaged: StatusUntracked})
+			if c.Status == StatusRenamed {
+				entry(c.OldPath).Unstaged = StatusDeleted
+			}
+		default:
+			entry(c.Path).Unstaged = c.Status
+		}
+	}
+
+	statuses := make([]FileStatus, 0, len(entries)+len(untracked))
+	for _, e := range entries {
+		statuses = append(statuses, *e)
+	}
+	statuses = append(statuses, untracked...)
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Path < statuses[j].Path
+	})
+	return statuses
+}
@@ -0,0 +1,84 @@
+package ops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestWorkingStatus(t *testing.T) {
+	head := []db.SnapshotFile{
+		{Path: "clean", ObjectHash: "c"},
+		{Path: "edited", ObjectHash: "e1"},
+		{Path: "removed", ObjectHash: "r"},
+		{Path: "staged", ObjectHash: "s1"},
+		{Path: "moved", ObjectHash: "m"},
+	}
+	stage := []db.SnapshotFile{
+		{Path: "clean", ObjectHash: "c"},
+		{Path: "edited", ObjectHash: "e1"},
+		{Path: "removed", ObjectHash: "r"},
+		{Path: "staged", ObjectHash: "s2"},
+		{Path: "moved", ObjectHash: "m"},
+		{Path: "new", ObjectHash: "n1"},
+	}
+	workspace := []db.SnapshotFile{
+		{Path: "clean", ObjectHash: "c"},
+		{Path: "edited", ObjectHash: "e2"},
+		{Path: "staged", ObjectHash: "s2"},
+		{Path: "new", ObjectHash: "n2"},
+		{Path: "moved-to", ObjectHash: "m"},
+		{Path: "scratch", ObjectHash: "x"},
+	}
+
+	unstaged, err := DetectRenames(DiffTrees(stage, workspace), stage, nil, nil, RenameOptions{Threshold: 101})
+	if err != nil {
+		t.Fatalf("detect renames: %v", err)
+	}
+
+	got := WorkingStatus(DiffTrees(head, stage), unstaged)
+	want := []FileStatus{
+		{Path: "edited", Unstaged: StatusModified},
+		{Path: "moved", Unstaged: StatusDeleted},
+		{Path: "moved-to", Staged: StatusUntracked, Unstaged: StatusUntracked},
+		{Path: "new", Staged: StatusAdded, Unstaged: StatusModified},
+		{Path: "removed", Unstaged: StatusDeleted},
+		{Path: "scratch", Staged: StatusUntracked, Unstaged: StatusUntracked},
+		{Path: "staged", Staged: StatusModified},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("working status:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	codes := map[string]string{}
+	for _, s := range got {
+		codes[s.Path] = s.Code()
+	}
+	wantCodes := map[string]string{
+		"edited":   " M",
+		"moved":    " D",
+		"moved-to": "??",
+		"new":      "AM",
+		"removed":  " D",
+		"scratch":  "??",
+		"staged":   "M ",
+	}
+	if !reflect.DeepEqual(codes, wantCodes) {
+		t.Fatalf("codes: got %v, want %v", codes, wantCodes)
+	}
+}
+
+func TestWorkingStatusUntrackedAfterStagedDeletion(t *testing.T) {
+	head := []db.SnapshotFile{{Path: "kept", ObjectHash: "k"}}
+	workspace := []db.SnapshotFile{{Path: "kept", ObjectHash: "k"}}
+
+	got := WorkingStatus(DiffTrees(head, nil), DiffTrees(nil, workspace))
+	want := []FileStatus{
+		{Path: "kept", Staged: StatusDeleted},
+		{Path: "kept", Staged: StatusUntracked, Unstaged: StatusUntracked},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("working status: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package ops
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
+	"github.com/greedypanda0/kuro/core/errors"
+)
+
+// Change is a single staged edit applied on top of the parent tree.
+// A deleted change removes the path; otherwise ObjectHash replaces it.
+type Change struct {
+	Path       string
+	ObjectHash string
+	Deleted    bool
+}
+
+// BuildTree returns the complete file list of the next snapshot: every
+// entry of the parent snapshot, overlaid with the given changes.
+func BuildTree(database db.DBTX, parentHash *string, changes []Change) ([]db.SnapshotFile, error) {
+	tree := map[string]string{}
+
+	if parentHash != nil {
+		parentFiles, err := db.ListSnapshotFiles(database, *parentHash)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range parentFiles {
+			tree[f.Path] = f.ObjectHash
+		}
+	}
+
+	for _, change := range changes {
+		if change.Deleted {
+			delete(tree, change.Path)
+			continue
+		}
+		tree[change.Path] = change.ObjectHash
+	}
+
+	files := make([]db.SnapshotFile, 0, len(tree))
+	for path, hash := range tree {
+		files = append(files, db.SnapshotFile{
+			Path:       path,
+			ObjectHash: hash,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+// SnapshotHash computes the content address of a snapshot from its
+// parents, message and full file list.
+func SnapshotHash(parents []string, message string, files []db.SnapshotFile) string {
+	sorted := make([]db.SnapshotFile, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	var builder strings.Builder
+	for _, parent := range parents {
+		builder.WriteString("parent:")
+		builder.WriteString(parent)
+		builder.WriteString("\n")
+	}
+	builder.WriteString("message:")
+	builder.WriteString(message)
+
+	for _, f := range sorted {
+		builder.WriteString("\npath:")
+		builder.WriteString(f.Path)
+		builder.WriteString("\nobject:")
+		builder.WriteString(f.ObjectHash)
+	}
+
+	return Hash([]byte(builder.String()))
+}
+
+// CommitTree records a snapshot with the given tree and returns its hash.
+func CommitTree(database db.DBTX, parents []string, message string, author *string, files []db.SnapshotFile) (string, error) {
+	snapshotHash := SnapshotHash(parents, message, files)
+
+	// Snapshots are content addressed, so an identical one is reused.
+	if _, err := db.GetSnapshot(database, snapshotHash); err == nil {
+		return snapshotHash, nil
+	} else if err != errors.ErrSnapshotNotFound {
+		return "", err
+	}
+
+	if err := db.CreateSnapshot(database, snapshotHash, parents, message, author); err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		if err := db.CreateSnapshotFile(database, snapshotHash, f.Path, f.ObjectHash); err != nil {
+			return "", err
+		}
+	}
+
+	return snapshotHash, nil
+}
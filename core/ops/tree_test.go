@@ -0,0 +1,93 @@
+package ops
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	database.SetMaxOpenConns(1)
+	t.Cleanup(func() { database.Close() })
+
+	if err := db.ApplySchema(database); err != nil {
+		t.Fatalf("apply schema: %v", err)
+	}
+
+	return database
+}
+
+func commitFiles(t *testing.T, database db.DBTX, parent *string, message string, changes []Change) string {
+	t.Helper()
+
+	for _, c := range changes {
+		if c.Deleted {
+			continue
+		}
+		if err := db.CreateObject(database, c.ObjectHash, []byte(c.ObjectHash)); err != nil {
+			t.Fatalf("create object: %v", err)
+		}
+	}
+
+	files, err := BuildTree(database, parent, changes)
+	if err != nil {
+		t.Fatalf("build tree: %v", err)
+	}
+
+	var parents []string
+	if parent != nil {
+		parents = []string{*parent}
+	}
+
+	hash, err := CommitTree(database, parents, message, nil, files)
+	if err != nil {
+		t.Fatalf("commit tree: %v", err)
+	}
+	return hash
+}
+
+func TestBuildTreeCarriesParentForward(t *testing.T) {
+	database := openTestDB(t)
+
+	first := commitFiles(t, database, nil, "first", []Change{
+		{Path: "a.txt", ObjectHash: "a1"},
+		{Path: "dir/b.txt", ObjectHash: "b1"},
+	})
+
+	files, err := BuildTree(database, &first, []Change{
+		{Path: "c.txt", ObjectHash: "c1"},
+		{Path: "a.txt", Deleted: true},
+	})
+	if err != nil {
+		t.Fatalf("build tree: %v", err)
+	}
+
+	want := []db.SnapshotFile{
+		{Path: "c.txt", ObjectHash: "c1"},
+		{Path: "dir/b.txt", ObjectHash: "b1"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("expected %d files, got %d", len(want), len(files))
+	}
+	for i := range want {
+		if files[i].Path != want[i].Path || files[i].ObjectHash != want[i].ObjectHash {
+			t.Fatalf("unexpected file %d: %+v", i, files[i])
+		}
+	}
+}
+
+func TestSnapshotHashIgnoresFileOrder(t *testing.T) {
+	a := []db.SnapshotFile{{Path: "a", ObjectHash: "1"}, {Path: "b", ObjectHash: "2"}}
+	b := []db.SnapshotFile{{Path: "b", ObjectHash: "2"}, {Path: "a", ObjectHash: "1"}}
+
+	if SnapshotHash(nil, "msg", a) != SnapshotHash(nil, "msg", b) {
+		t.Fatalf("expected identical hashes for reordered files")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/greedypanda0/kuro/core/db"
 )
 
 type File struct {
@@ -56,3 +59,110 @@ func ReadDir(root string) ([]File, error) {
 
 	return files, err
 }
+
+// DirtyPaths returns the paths of files whose workspace content differs
+// from the given tree, including tracked files that were deleted.
+func DirtyPaths(root string, files []db.SnapshotFile) ([]string, error) {
+	var dirty []string
+	for _, f := range files {
+		hash, err := HashFile(filepath.Join(root, filepath.FromSlash(f.Path)))
+		if os.IsNotExist(err) {
+			dirty = append(dirty, f.Path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hash != f.ObjectHash {
+			dirty = append(dirty, f.Path)
+		}
+	}
+	return dirty, nil
+}
+
+// UntrackedPaths returns the workspace files that are neither tracked nor
+// ignored, sorted by path.
+func UntrackedPaths(root string, tracked []string, ignore []string) ([]string, error) {
+	files, err := ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]struct{}, len(tracked))
+	for _, path := range tracked {
+		known[path] = struct{}{}
+	}
+
+	var untracked []string
+	for _, f := range files {
+		if _, ok := known[f.Path]; ok || IsIgnored(f.Path, ignore) {
+			continue
+		}
+		untracked = append(untracked, f.Path)
+	}
+	sort.Strings(untracked)
+	return untracked, nil
+}
+
+// DirtyConflicts returns the conflicted paths whose workspace content
+// differs from ours, the tree the conflict markers are written over.
+// Conflicted paths keep our hash in the merged tree, so a check of the
+// tree alone does not see that writing the markers loses these edits.
+func DirtyConflicts(root string, ours []db.SnapshotFile, conflicts []MergeConflict) ([]string, error) {
+	oursMap := make(map[string]string, len(ours))
+	for _, f := range ours {
+		oursMap[f.Path] = f.ObjectHash
+	}
+
+	var dirty []string
+	for _, c := range conflicts {
+		expected, tracked := oursMap[c.Path]
+		hash, err := HashFile(filepath.Join(root, filepath.FromSlash(c.Path)))
+		if os.IsNotExist(err) {
+			if tracked {
+				dirty = append(dirty, c.Path)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !tracked || hash != expected {
+			dirty = append(dirty, c.Path)
+		}
+	}
+	return dirty, nil
+}
+
+// WorkspaceTree stores the current workspace content of the paths in
+// files plus extra and returns the resulting tree. Paths missing from the
+// workspace are left out.
+func WorkspaceTree(database db.DBTX, root string, files []db.SnapshotFile, extra []string) ([]db.SnapshotFile, error) {
+	previous := make(map[string]string, len(files))
+	for _, f := range files {
+		previous[f.Path] = f.ObjectHash
+	}
+	for _, path := range extra {
+		if _, ok := previous[path]; !ok {
+			previous[path] = ""
+		}
+	}
+
+	tree := make([]db.SnapshotFile, 0, len(previous))
+	for path, base := range previous {
+		hash, err := StoreFile(database, filepath.Join(root, filepath.FromSlash(path)), base)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tree = append(tree, db.SnapshotFile{Path: path, ObjectHash: hash})
+	}
+
+	sort.Slice(tree, func(i, j int) bool {
+		return tree[i].Path < tree[j].Path
+	})
+
+	return tree, nil
+}
@@ -0,0 +1,125 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/greedypanda0/kuro/core/db"
+)
+
+func TestWorkspaceTreeAndDirtyPaths(t *testing.T) {
+	database := openTestDB(t)
+	root := t.TempDir()
+
+	write := func(path, content string) {
+		t.Helper()
+		abs := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	write("kept.txt", "kept\n")
+	write("edited.txt", "before\n")
+	write("gone.txt", "gone\n")
+
+	head, err := WorkspaceTree(database, root, nil, []string{"kept.txt", "edited.txt", "gone.txt"})
+	if err != nil {
+		t.Fatalf("workspace tree: %v", err)
+	}
+
+	dirty, err := DirtyPaths(root, head)
+	if err != nil {
+		t.Fatalf("dirty paths: %v", err)
+	}
+	if len(dirty) != 0 {
+		t.Fatalf("expected clean workspace, got %v", dirty)
+	}
+
+	write("edited.txt", "after\n")
+	write("dir/new.txt", "new\n")
+	if err := os.Remove(filepath.Join(root, "gone.txt")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	dirty, err = DirtyPaths(root, head)
+	if err != nil {
+		t.Fatalf("dirty paths: %v", err)
+	}
+	if want := []string{"edited.txt", "gone.txt"}; !reflect.DeepEqual(dirty, want) {
+		t.Fatalf("dirty paths: got %v, want %v", dirty, want)
+	}
+
+	tree, err := WorkspaceTree(database, root, head, []string{"dir/new.txt"})
+	if err != nil {
+		t.Fatalf("workspace tree: %v", err)
+	}
+
+	want := []db.SnapshotFile{
+		{Path: "dir/new.txt", ObjectHash: Hash([]byte("new\n"))},
+		{Path: "edited.txt", ObjectHash: Hash([]byte("after\n"))},
+		{Path: "kept.txt", ObjectHash: Hash([]byte("kept\n"))},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Fatalf("workspace tree: got %+v, want %+v", tree, want)
+	}
+}
+
+func TestDirtyConflicts(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"clean":     "clean\n",
+		"edited":    "local edit\n",
+		"untracked": "untracked\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	// Conflicted paths keep our hash in the merged tree, so only the
+	// workspace shows that "edited" has local changes.
+	ours := []db.SnapshotFile{
+		{Path: "clean", ObjectHash: Hash([]byte("clean\n"))},
+		{Path: "edited", ObjectHash: Hash([]byte("ours\n"))},
+		{Path: "removed", ObjectHash: Hash([]byte("removed\n"))},
+	}
+	var conflicts []MergeConflict
+	for _, path := range []string{"clean", "edited", "removed", "untracked", "absent"} {
+		conflicts = append(conflicts, MergeConflict{Path: path, Content: []byte("<<<<<<<\n")})
+	}
+
+	dirty, err := DirtyConflicts(root, ours, conflicts)
+	if err != nil {
+		t.Fatalf("dirty conflicts: %v", err)
+	}
+	if want := []string{"edited", "removed", "untracked"}; !reflect.DeepEqual(dirty, want) {
+		t.Fatalf("dirty conflicts: got %v, want %v", dirty, want)
+	}
+}
+
+func TestUntrackedPaths(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{"tracked.txt", "notes.txt", "build/out.bin", "dir/draft.txt", ".hidden"} {
+		abs := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(abs, []byte(path), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	untracked, err := UntrackedPaths(root, []string{"tracked.txt", "gone.txt"}, []string{"build/"})
+	if err != nil {
+		t.Fatalf("untracked paths: %v", err)
+	}
+	if want := []string{"dir/draft.txt", "notes.txt"}; !reflect.DeepEqual(untracked, want) {
+		t.Fatalf("untracked paths: got %v, want %v", untracked, want)
+	}
+}